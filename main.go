@@ -1,17 +1,78 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/healthy-tiger/scalc/parser"
 	"github.com/healthy-tiger/scalc/runtime"
 )
 
+// localeFromEnv 環境変数LC_ALL, LC_MESSAGES, LANGの順にロケールを調べ、言語部分を返す。
+func localeFromEnv() string {
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(k); v != "" {
+			if i := strings.IndexAny(v, "_.@"); i >= 0 {
+				v = v[:i]
+			}
+			return v
+		}
+	}
+	return parser.LocaleEnglish
+}
+
+// holidayFiles -holidaysで指定された祝日の暦のファイル。name=pathの形式で複数指定できる。
+type holidayFiles []string
+
+func (h *holidayFiles) String() string {
+	return strings.Join(*h, ",")
+}
+
+func (h *holidayFiles) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("expected name=path: %v", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
 func main() {
+	intBool := flag.Bool("intbool", false, "represent true and false as 1 and 0 for old scripts")
+	promote := flag.Bool("promote", false, "promote integers to floats in mixed arithmetic and comparisons")
+	calendar := flag.String("calendar", "", "default holiday calendar for business-day functions (e.g. jp)")
+	tz := flag.String("tz", "", "default time zone for time functions (e.g. Asia/Tokyo, UTC)")
+	var holidays holidayFiles
+	flag.Var(&holidays, "holidays", "load a holiday calendar file as name=path (repeatable)")
+	flag.Parse()
+	runtime.SetLocale(localeFromEnv())
+
+	for _, h := range holidays {
+		i := strings.Index(h, "=")
+		if err := runtime.LoadHolidayCalendar(h[:i], h[i+1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
 	st := parser.NewSymbolTable()
 	ns := runtime.NewRootNamespace(st)
+	ns.Config().IntBool = *intBool
+	ns.Config().NumericPromotion = *promote
+	ns.Config().Calendar = *calendar
+	if *tz != "" {
+		loc, err := time.LoadLocation(*tz)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		ns.Config().Location = loc
+	}
 	runtime.MakeDefaultNamespace(ns)
+	runtime.RegisterSession(ns)
+	ns.LockBuiltins()
 
 	lst, err := parser.Parse("stdin", st, os.Stdin)
 	if err != nil {
@@ -12,4 +12,8 @@ const (
 	rightSquareBracket = ']'
 	leftCurlyBracket   = '{'
 	rightCurlyBracket  = '}'
+	colon              = ':'
+	percent            = '%'
+
+	interpolationPrefix = 'f' // 埋め込み式付き文字列リテラルの接頭辞
 )
@@ -3,6 +3,7 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 )
 
 // 内部エラーの定義
@@ -24,23 +25,84 @@ const (
 	ErrorNotStringLiteral               = iota
 	ErrorTopLevelElementMustBeAList     = iota
 	ErrorMissingClosingParenthesis      = iota
+	ErrorInvalidInterpolation           = iota
 )
 
-var errorMessages map[int]string
+// エラーメッセージのロケールの定義
+const (
+	LocaleEnglish  = "en"
+	LocaleJapanese = "ja"
+)
+
+// errorMessages ロケールごとのエラーメッセージのカタログ
+var errorMessages map[string]map[int]string
+
+// currentLocale エラーメッセージを生成する際に使用するロケール。評価中の他のゴルーチンから読まれるためatomic.Valueに格納する。
+var currentLocale atomic.Value
 
 func init() {
-	errorMessages = map[int]string{
-		ErrorUnmatchedParenthesis:           "Unmatched parenthesis",
-		ErrorUnexpectedToken:                "Unexpected token",
-		ErrorUnexpectedInputChar:            "Unexpected input char '%c'",
-		ErrorInsufficientInput:              "Insufficient input",
-		ErrorFirstElementTypeMustBeASymbol:  "First element type must be a symbol",
-		ErrorStringLiteralMustBeASingleLine: "String literal must be a single line",
-		ErrorIllegalEscapeSequence:          "Illegal escape sequence '%c'",
-		ErrorNotStringLiteral:               "Not string literal",
-		ErrorTopLevelElementMustBeAList:     "Top-level element must be a list",
-		ErrorMissingClosingParenthesis:      "Missing closing parenthesis",
+	currentLocale.Store(LocaleEnglish)
+	errorMessages = map[string]map[int]string{
+		LocaleEnglish: {
+			ErrorUnmatchedParenthesis:           "Unmatched parenthesis",
+			ErrorUnexpectedToken:                "Unexpected token",
+			ErrorUnexpectedInputChar:            "Unexpected input char '%c'",
+			ErrorInsufficientInput:              "Insufficient input",
+			ErrorFirstElementTypeMustBeASymbol:  "First element type must be a symbol",
+			ErrorStringLiteralMustBeASingleLine: "String literal must be a single line",
+			ErrorIllegalEscapeSequence:          "Illegal escape sequence '%c'",
+			ErrorNotStringLiteral:               "Not string literal",
+			ErrorTopLevelElementMustBeAList:     "Top-level element must be a list",
+			ErrorMissingClosingParenthesis:      "Missing closing parenthesis",
+			ErrorInvalidInterpolation:           "Invalid interpolation '%v'",
+		},
+		LocaleJapanese: {
+			ErrorUnmatchedParenthesis:           "括弧の対応が取れていません",
+			ErrorUnexpectedToken:                "予期しないトークンです",
+			ErrorUnexpectedInputChar:            "予期しない入力文字 '%c' です",
+			ErrorInsufficientInput:              "入力が不足しています",
+			ErrorFirstElementTypeMustBeASymbol:  "最初の要素はシンボルでなければなりません",
+			ErrorStringLiteralMustBeASingleLine: "文字列リテラルは1行で記述しなければなりません",
+			ErrorIllegalEscapeSequence:          "不正なエスケープシーケンス '%c' です",
+			ErrorNotStringLiteral:               "文字列リテラルではありません",
+			ErrorTopLevelElementMustBeAList:     "トップレベルの要素はリストでなければなりません",
+			ErrorMissingClosingParenthesis:      "閉じ括弧がありません",
+			ErrorInvalidInterpolation:           "不正な埋め込み式 '%v' です",
+		},
+	}
+}
+
+// SetLocale エラーメッセージの生成に使用するロケールを設定する。
+// カタログに無いメッセージは英語のメッセージで代替する。複数のゴルーチンから同時に呼び出してもよい。
+func SetLocale(locale string) {
+	currentLocale.Store(locale)
+}
+
+// Locale 現在設定されているロケールを返す。
+func Locale() string {
+	return currentLocale.Load().(string)
+}
+
+// RegisterErrorMessage ロケールlocaleのカタログにエラーID idのエラーメッセージを登録する。
+// カタログが存在しないロケールの場合は新たにカタログを作る。
+func RegisterErrorMessage(locale string, id int, msg string) {
+	if _, ok := errorMessages[LocaleEnglish][id]; !ok {
+		panic("Undefined error id")
 	}
+	c, ok := errorMessages[locale]
+	if !ok {
+		c = make(map[int]string)
+		errorMessages[locale] = c
+	}
+	c[id] = msg
+}
+
+// lookupErrorMessage ロケールlocaleのカタログからエラーメッセージを探す。見つからない場合は英語のメッセージを返す。
+func lookupErrorMessage(locale string, id int) string {
+	if m, ok := errorMessages[locale][id]; ok {
+		return m
+	}
+	return errorMessages[LocaleEnglish][id]
 }
 
 // ParseError パース時のエラーメッセージを格納する
@@ -51,8 +113,13 @@ type ParseError struct {
 }
 
 func (err *ParseError) Error() string {
+	return err.Localize(Locale())
+}
+
+// Localize ロケールlocaleのエラーメッセージを返す。
+func (err *ParseError) Localize(locale string) string {
 	h := fmt.Sprintf("%s:%d:%d ", err.ErrorLocation.Filename, err.ErrorLocation.Line, err.ErrorLocation.Column)
-	m := errorMessages[err.ID]
+	m := lookupErrorMessage(locale, err.ID)
 	if err.Arg != nil {
 		m = fmt.Sprintf(m, err.Arg)
 	}
@@ -60,7 +127,7 @@ func (err *ParseError) Error() string {
 }
 
 func newError(filename string, line int, column int, messageid int, arg interface{}) *ParseError {
-	if _, ok := errorMessages[messageid]; !ok {
+	if _, ok := errorMessages[LocaleEnglish][messageid]; !ok {
 		panic("Undefined error id")
 	}
 	return &ParseError{Position{filename, line, column}, messageid, arg}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"strings"
+)
+
+// InterpolationSymbol 埋め込み式付き文字列リテラルを展開した関数呼び出しの関数名。
+// f"Total: {x:.2f} yen"は(str-interpolate "Total: %.2f yen" x)として読み込まれる。
+const InterpolationSymbol = "str-interpolate"
+
+// parseInterpolation 埋め込み式付き文字列リテラルのテキストrawを、書式と埋め込み式を引数とするInterpolationSymbolの呼び出しに変換する。
+// 埋め込み式を含まない場合は通常の文字列リテラルにする。line、columnは接頭辞'f'の位置。
+func parseInterpolation(raw string, st *SymbolTable, filename string, line int, column int) (SyntaxElement, error) {
+	rs := []rune(raw)
+	var format strings.Builder
+	var text strings.Builder // 書式に追加する前の、エスケープシーケンスを解釈していない文字列
+	args := make([]SyntaxElement, 0)
+	// 埋め込み式の前までの文字列のエスケープシーケンスを解釈し、'%'をエスケープして書式に追加する。
+	flush := func() error {
+		s, err := unescape(text.String(), filename, line, column)
+		if err != nil {
+			return err
+		}
+		format.WriteString(strings.Replace(s, string(percent), "%%", -1))
+		text.Reset()
+		return nil
+	}
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == backslash && i+1 < len(rs):
+			text.WriteRune(r)
+			text.WriteRune(rs[i+1])
+			i++
+		case (r == leftCurlyBracket || r == rightCurlyBracket) && i+1 < len(rs) && rs[i+1] == r:
+			// "{{"と"}}"はそれぞれ'{'と'}'を表す。
+			text.WriteRune(r)
+			i++
+		case r == rightCurlyBracket:
+			return nil, newError(filename, line, column+2+i, ErrorInvalidInterpolation, string(r))
+		case r == leftCurlyBracket:
+			end := matchingCurlyBracket(rs, i)
+			if end < 0 {
+				return nil, newError(filename, line, column+2+i, ErrorInvalidInterpolation, string(rs[i:]))
+			}
+			expr, spec := splitFormatSpec(string(rs[i+1 : end]))
+			se, err := parseEmbeddedExpression(expr, st, filename, line, column+2+i+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			format.WriteRune(percent)
+			format.WriteString(spec)
+			args = append(args, se)
+			i = end
+		default:
+			text.WriteRune(r)
+		}
+	}
+	if len(args) == 0 {
+		s, err := unescape(text.String(), filename, line, column)
+		if err != nil {
+			return nil, err
+		}
+		return newLiteral(s, filename, line, column), nil
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	pos := Position{filename, line, column}
+	elements := []SyntaxElement{NewSymbol(st.GetSymbolID(InterpolationSymbol), pos), newLiteral(format.String(), filename, line, column)}
+	return NewList(pos, append(elements, args...)...), nil
+}
+
+// matchingCurlyBracket rsのopen番目の'{'に対応する'}'の位置を返す。埋め込み式の中の文字列リテラルは読み飛ばす。
+// 対応する'}'がない場合は-1を返す。
+func matchingCurlyBracket(rs []rune, open int) int {
+	depth := 0
+	quoted := false
+	for i := open; i < len(rs); i++ {
+		switch r := rs[i]; {
+		case r == backslash:
+			i++
+		case quoted:
+			quoted = r != doublequote
+		case r == doublequote:
+			quoted = true
+		case r == leftCurlyBracket:
+			depth++
+		case r == rightCurlyBracket:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitFormatSpec 埋め込み式exprの末尾に":.2f"のような書式指定があれば、式と書式指定（"%"以降）に分ける。
+// 書式指定がない場合は"v"を返す。
+func splitFormatSpec(expr string) (string, string) {
+	i := strings.LastIndexByte(expr, colon)
+	if i < 0 || !isFormatSpec(expr[i+1:]) {
+		return expr, "v"
+	}
+	return expr[:i], expr[i+1:]
+}
+
+// isFormatSpec specがフラグ、幅、精度と書式指定子一文字からなる場合はtrueを返す。
+func isFormatSpec(spec string) bool {
+	i := 0
+	for i < len(spec) && strings.IndexByte("+-# 0", spec[i]) >= 0 {
+		i++
+	}
+	for i < len(spec) && spec[i] >= '0' && spec[i] <= '9' {
+		i++
+	}
+	if i < len(spec) && spec[i] == '.' {
+		i++
+		for i < len(spec) && spec[i] >= '0' && spec[i] <= '9' {
+			i++
+		}
+	}
+	return i == len(spec)-1 && ((spec[i] >= 'a' && spec[i] <= 'z') || (spec[i] >= 'A' && spec[i] <= 'Z'))
+}
+
+// parseEmbeddedExpression 埋め込み式exprを一つの構文要素として読み込む。columnはexprの先頭の位置。
+func parseEmbeddedExpression(expr string, st *SymbolTable, filename string, line int, column int) (SyntaxElement, error) {
+	// 括弧で囲んで読み込み、その唯一の要素を取り出す。
+	tokenizer, err := newTokenizer(filename, strings.NewReader(string(leftParenthesis)+expr+string(rightParenthesis)))
+	if err != nil {
+		return nil, err
+	}
+	tokenizer.line = line
+	tokenizer.column = column - 1
+	lists, err := parse(tokenizer, st)
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) != 1 || lists[0].Len() != 1 {
+		return nil, newError(filename, line, column, ErrorInvalidInterpolation, expr)
+	}
+	return lists[0].ElementAt(0), nil
+}
+
+// unescape 文字列リテラルのテキストrawのエスケープシーケンスを解釈した文字列を返す。
+func unescape(raw string, filename string, line int, column int) (string, error) {
+	ss := &stokenizer{filename, nil, strings.NewReader(raw + string(doublequote)), "", line, column}
+	s, _, err := ss.readString()
+	return s, err
+}
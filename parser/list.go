@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // SyntaxElement 構文要素を表す。
@@ -13,6 +14,7 @@ type SyntaxElement interface {
 	FloatValue() (float64, bool)
 	StringValue() (string, bool)
 	SymbolValue() (SymbolID, bool)
+	DurationValue() (time.Duration, bool)
 	ElementAt(int) SyntaxElement
 }
 
@@ -33,6 +35,16 @@ const nilInt = 0
 const nilFloat = 0.0
 const emptyString = ""
 
+// NewList elementsを子要素に持つ丸括弧のリストを生成する。ソースコードを経由せずに構文木を組み立てる場合に使う。
+func NewList(pos Position, elements ...SyntaxElement) *List {
+	return &List{leftParenthesis, elements, pos}
+}
+
+// NewSymbol シンボルidを表す構文要素を生成する。
+func NewSymbol(id SymbolID, pos Position) SyntaxElement {
+	return &symbolIDElement{id, pos}
+}
+
 func (lst *List) isMatchingParen(close rune) bool {
 	if (lst.openchar == leftParenthesis && close == rightParenthesis) ||
 		(lst.openchar == leftSquareBracket && close == rightSquareBracket) ||
@@ -77,6 +89,11 @@ func (lst *List) SymbolValue() (SymbolID, bool) {
 	return InvalidSymbolID, false
 }
 
+// DurationValue lstは時間の長さの値を持たない。
+func (lst *List) DurationValue() (time.Duration, bool) {
+	return 0, false
+}
+
 // ElementAt lstのindex番目の要素を返す。
 func (lst *List) ElementAt(index int) SyntaxElement {
 	if index < 0 || index >= len(lst.elements) {
@@ -131,6 +148,8 @@ func newLiteral(value interface{}, filename string, line int, column int) Syntax
 		return &symbolIDElement{v, Position{filename, line, column}}
 	case string:
 		return &stringElement{v, Position{filename, line, column}}
+	case time.Duration:
+		return &durationElement{v, Position{filename, line, column}}
 	}
 	panic(fmt.Sprintf("Unexpected value type: %v", reflect.TypeOf(value)))
 }
@@ -170,6 +189,11 @@ func (e *intElement) SymbolValue() (SymbolID, bool) {
 	return InvalidSymbolID, false
 }
 
+// DurationValue eが時間の長さのリテラルなら、リテラルのtime.Durationの値を返す。
+func (e *intElement) DurationValue() (time.Duration, bool) {
+	return 0, false
+}
+
 func (e *intElement) ElementAt(_ int) SyntaxElement {
 	return nil
 }
@@ -209,6 +233,11 @@ func (e *floatElement) SymbolValue() (SymbolID, bool) {
 	return InvalidSymbolID, false
 }
 
+// DurationValue eが時間の長さのリテラルなら、リテラルのtime.Durationの値を返す。
+func (e *floatElement) DurationValue() (time.Duration, bool) {
+	return 0, false
+}
+
 func (e *floatElement) ElementAt(_ int) SyntaxElement {
 	return nil
 }
@@ -248,6 +277,11 @@ func (e *stringElement) SymbolValue() (SymbolID, bool) {
 	return InvalidSymbolID, false
 }
 
+// DurationValue eが時間の長さのリテラルなら、リテラルのtime.Durationの値を返す。
+func (e *stringElement) DurationValue() (time.Duration, bool) {
+	return 0, false
+}
+
 func (e *stringElement) ElementAt(_ int) SyntaxElement {
 	return nil
 }
@@ -287,6 +321,55 @@ func (e *symbolIDElement) SymbolValue() (SymbolID, bool) {
 	return e.value, true
 }
 
+// DurationValue eが時間の長さのリテラルなら、リテラルのtime.Durationの値を返す。
+func (e *symbolIDElement) DurationValue() (time.Duration, bool) {
+	return 0, false
+}
+
 func (e *symbolIDElement) ElementAt(_ int) SyntaxElement {
 	return nil
 }
+
+type durationElement struct {
+	value time.Duration
+	pos   Position
+}
+
+// IsList eがリストならtrueを返す。
+func (e *durationElement) IsList() bool {
+	return false
+}
+
+// Position eのソースコード上の位置を返す。
+func (e *durationElement) Position() Position {
+	return e.pos
+}
+
+// IntValue eが整数リテラルなら、整数リテラルのint64型の値を返す。
+func (e *durationElement) IntValue() (int64, bool) {
+	return nilInt, false
+}
+
+// FloatValue eが浮動小数点数リテラルなら、浮動小数点数リテラルのfloat64の値を返す。
+func (e *durationElement) FloatValue() (float64, bool) {
+	return nilFloat, false
+}
+
+// StringValue eが文字列リテラルなら、文字列リテラルのstringの値を返す。
+func (e *durationElement) StringValue() (string, bool) {
+	return emptyString, false
+}
+
+// SymbolValue eがシンボルなら、リテラルのSymbolIDを返す。
+func (e *durationElement) SymbolValue() (SymbolID, bool) {
+	return InvalidSymbolID, false
+}
+
+// DurationValue eが時間の長さのリテラルなら、リテラルのtime.Durationの値を返す。
+func (e *durationElement) DurationValue() (time.Duration, bool) {
+	return e.value, true
+}
+
+func (e *durationElement) ElementAt(_ int) SyntaxElement {
+	return nil
+}
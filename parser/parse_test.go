@@ -1,6 +1,10 @@
 package parser
 
-import "testing"
+import (
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestParse1(t *testing.T) {
 	src := `(1 2 3)`
@@ -219,3 +223,149 @@ func TestParse13(t *testing.T) {
 		}
 	}
 }
+
+func TestParse14(t *testing.T) {
+	src := `(1 2 3`
+
+	st := NewSymbolTable()
+	_, err := ParseString("TestParse14", st, src)
+	if err == nil {
+		t.Fatal("No parse error")
+	}
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Unexpected error type: %v", err)
+	}
+	if m := pe.Localize(LocaleEnglish); m != "TestParse14:1:7 Missing closing parenthesis" {
+		t.Errorf("Unexpected message: %s", m)
+	}
+	if m := pe.Localize(LocaleJapanese); m != "TestParse14:1:7 閉じ括弧がありません" {
+		t.Errorf("Unexpected message: %s", m)
+	}
+	if m := pe.Localize("xx"); m != "TestParse14:1:7 Missing closing parenthesis" {
+		t.Errorf("Unexpected message: %s", m)
+	}
+}
+
+func TestSetLocaleConcurrently(t *testing.T) {
+	defer SetLocale(Locale())
+
+	st := NewSymbolTable()
+	_, err := ParseString("TestSetLocaleConcurrently", st, `(1 2 3`)
+	if err == nil {
+		t.Fatal("No parse error")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i%2 == 0 {
+					SetLocale(LocaleJapanese)
+				} else {
+					SetLocale(LocaleEnglish)
+				}
+				if m := err.Error(); m != "TestSetLocaleConcurrently:1:7 Missing closing parenthesis" && m != "TestSetLocaleConcurrently:1:7 閉じ括弧がありません" {
+					t.Errorf("Unexpected message: %s", m)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestParseInterpolation(t *testing.T) {
+	src := `(foo f"a{x}%{(+ y "}") :.2f}{{z}}" f"{{plain}}\t" fx)`
+
+	st := NewSymbolTable()
+	lists, err := ParseString("TestParseInterpolation", st, src)
+	if err != nil {
+		t.Fatalf("Parse error with \"%v\"", err)
+	}
+	if s, err := Format(lists[0], st); err != nil || s != `(foo (str-interpolate "a%v%%%.2f{z}" x (+ y "}")) "{plain}\t" fx)` {
+		t.Errorf("Unexpected result %s", s)
+	}
+	y := lists[0].ElementAt(1).ElementAt(3).ElementAt(1)
+	if y.Position().Column != 17 {
+		t.Errorf("Unexpected position %v", y.Position())
+	}
+	if c := lists[0].ElementAt(3).Position().Column; c != 51 {
+		t.Errorf("Unexpected position %d", c)
+	}
+
+	for _, src := range []string{`(f"{}")`, `(f"{x y}")`, `(f"}")`, `(f"{x")`} {
+		_, err := ParseString("TestParseInterpolation", st, src)
+		if pe, ok := err.(*ParseError); !ok || (pe.ID != ErrorInvalidInterpolation && pe.ID != ErrorStringLiteralMustBeASingleLine) {
+			t.Errorf("Unexpected error %v for %s", err, src)
+		}
+	}
+	// 閉じられていない埋め込み式は'{'の位置を示す。
+	for _, tst := range []struct {
+		src    string
+		column int
+	}{
+		{`(a f"x{")`, 7},
+		{`(a f"x{(str "{")")`, 7},
+		{`(a f"x{{}}{y")`, 11},
+	} {
+		_, err := ParseString("TestParseInterpolation", st, tst.src)
+		if pe, ok := err.(*ParseError); !ok || pe.ID != ErrorInvalidInterpolation || pe.ErrorLocation.Column != tst.column {
+			t.Errorf("Unexpected error %v for %s", err, tst.src)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	src := `(1h30m -1.5s 10 1h30 h)`
+
+	st := NewSymbolTable()
+	lists, err := ParseString("TestParseDuration", st, src)
+	if err != nil {
+		t.Fatalf("Parse error with \"%v\"", err)
+	}
+	if d, ok := lists[0].ElementAt(0).DurationValue(); !ok || d != 90*time.Minute {
+		t.Errorf("Value parse error %v", d)
+	}
+	if d, ok := lists[0].ElementAt(1).DurationValue(); !ok || d != -1500*time.Millisecond {
+		t.Errorf("Value parse error %v", d)
+	}
+	for i := 2; i < lists[0].Len(); i++ {
+		if _, ok := lists[0].ElementAt(i).DurationValue(); ok {
+			t.Errorf("Parsed as a duration at %v", lists[0].ElementAt(i).Position())
+		}
+	}
+	if s, err := Format(lists[0], st); err != nil || s != "(1h30m0s -1.5s 10 1h30 h)" {
+		t.Errorf("Unexpected result %s", s)
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	st := NewSymbolTable()
+	names := []string{"abc", "def", "ghi"}
+	for i, n := range names {
+		if id := st.GetSymbolID(n); id != SymbolID(i) {
+			t.Errorf("Unexpected symbol id %d for %s", id, n)
+		}
+	}
+	if st.GetSymbolID("def") != 1 || st.Len() != 3 {
+		t.Errorf("Symbol was registered twice")
+	}
+	for i, n := range st.Symbols() {
+		if n != names[i] {
+			t.Errorf("Unexpected symbol %s", n)
+		}
+		if s, err := st.GetSymbolName(SymbolID(i)); err != nil || s != n {
+			t.Errorf("Symbol lookup error %d", i)
+		}
+	}
+	if _, err := st.GetSymbolName(3); err != ErrorUndefinedSymbol {
+		t.Errorf("Undefined symbol was found")
+	}
+	if id, ok := st.Lookup("ghi"); !ok || id != 2 {
+		t.Errorf("Symbol lookup error %s", "ghi")
+	}
+	if _, ok := st.Lookup("jkl"); ok || st.Len() != 3 {
+		t.Errorf("Lookup registered a new symbol")
+	}
+}
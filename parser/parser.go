@@ -5,37 +5,78 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// SymbolTable シンボルIDとシンボル名のマップ
+// SymbolTable シンボルIDとシンボル名のマップ。複数のゴルーチンから同時に使用できる。
 type SymbolTable struct {
+	mutex     sync.RWMutex
 	symbolMap map[string]SymbolID
+	names     []string // シンボルIDをインデックスとするシンボル名の配列
 }
 
 // NewSymbolTable 新しいSymbolTableを作る。
 func NewSymbolTable() *SymbolTable {
-	return &SymbolTable{make(map[string]SymbolID)}
+	return &SymbolTable{symbolMap: make(map[string]SymbolID), names: make([]string, 0)}
 }
 
 // GetSymbolID はシンボルnameに対するIDを返す。
 // IDが割り当てられていないシンボルに対しては、新たにIDを割り当てて返す。
 func (st *SymbolTable) GetSymbolID(name string) SymbolID {
+	st.mutex.RLock()
 	n, ok := st.symbolMap[name]
+	st.mutex.RUnlock()
+	if ok {
+		return n
+	}
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	// ロックを取り直す間に他のゴルーチンが割り当てている可能性があるので再度確認する。
+	n, ok = st.symbolMap[name]
 	if !ok {
-		n = SymbolID(len(st.symbolMap))
-		st.symbolMap[name] = SymbolID(n)
+		n = SymbolID(len(st.names))
+		st.symbolMap[name] = n
+		st.names = append(st.names, name)
 	}
 	return n
 }
 
+// Lookup はシンボルnameに対するIDを返す。GetSymbolIDと異なり、IDが割り当てられていない場合は新たに割り当てない。
+func (st *SymbolTable) Lookup(name string) (SymbolID, bool) {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	n, ok := st.symbolMap[name]
+	if !ok {
+		return InvalidSymbolID, false
+	}
+	return n, true
+}
+
 // GetSymbolName はシンボルのIDからシンボル名を取得する。
 func (st *SymbolTable) GetSymbolName(id SymbolID) (string, error) {
-	for k, v := range st.symbolMap {
-		if v == id {
-			return k, nil
-		}
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	if id < 0 || int(id) >= len(st.names) {
+		return "", ErrorUndefinedSymbol
 	}
-	return "", ErrorUndefinedSymbol
+	return st.names[id], nil
+}
+
+// Len 登録済みのシンボルの数を返す。
+func (st *SymbolTable) Len() int {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	return len(st.names)
+}
+
+// Symbols 登録済みのシンボル名をシンボルIDの順に並べたスライスを返す。スライスのインデックスがシンボルIDになる。
+func (st *SymbolTable) Symbols() []string {
+	st.mutex.RLock()
+	defer st.mutex.RUnlock()
+	names := make([]string, len(st.names))
+	copy(names, st.names)
+	return names
 }
 
 // Position ソースコード上の位置を表す
@@ -47,12 +88,18 @@ type Position struct {
 
 // Parse srcをスキャンしてSTreeを返す。
 func Parse(filename string, st *SymbolTable, src io.Reader) ([]*List, error) {
-	lists := make([]*List, 0)
-	stack := newStack()
 	tokenizer, err := newTokenizer(filename, src)
 	if err != nil {
 		return nil, err
 	}
+	return parse(tokenizer, st)
+}
+
+// parse tokenizerから読み込んだトークンを構文解析してSTreeを返す。
+func parse(tokenizer *stokenizer, st *SymbolTable) ([]*List, error) {
+	filename := tokenizer.inputname
+	lists := make([]*List, 0)
+	stack := newStack()
 	tok, line, column, err := tokenizer.scan()
 	for err == nil {
 		toktxt := tokenizer.tokentext()
@@ -62,7 +109,7 @@ func Parse(filename string, st *SymbolTable, src io.Reader) ([]*List, error) {
 			if lst == nil {
 				return nil, newError(filename, line, column, ErrorTopLevelElementMustBeAList, nil)
 			}
-			// IntかFloatとして処理できるか先に確認し、どちらもダメならシンボルにする。
+			// IntかFloat、時間の長さ（1h30mなど）として処理できるか先に確認し、どれもダメならシンボルにする。
 			vi, err := strconv.ParseInt(toktxt, 0, 64)
 			if err == nil {
 				lst.elements = append(lst.elements, newLiteral(vi, filename, line, column))
@@ -70,6 +117,8 @@ func Parse(filename string, st *SymbolTable, src io.Reader) ([]*List, error) {
 				vf, err := strconv.ParseFloat(toktxt, 64)
 				if err == nil {
 					lst.elements = append(lst.elements, newLiteral(vf, filename, line, column))
+				} else if vd, err := time.ParseDuration(toktxt); err == nil {
+					lst.elements = append(lst.elements, newLiteral(vd, filename, line, column))
 				} else {
 					lst.elements = append(lst.elements, newLiteral(st.GetSymbolID(toktxt), filename, line, column))
 				}
@@ -82,6 +131,17 @@ func Parse(filename string, st *SymbolTable, src io.Reader) ([]*List, error) {
 			}
 			lst.elements = append(lst.elements, newLiteral(toktxt, filename, line, column))
 
+		case interpolatedString:
+			lst := stack.peek()
+			if lst == nil {
+				return nil, newError(filename, line, column, ErrorTopLevelElementMustBeAList, nil)
+			}
+			se, err := parseInterpolation(toktxt, st, filename, line, column)
+			if err != nil {
+				return nil, err
+			}
+			lst.elements = append(lst.elements, se)
+
 		case commentText:
 
 		default:
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Format 構文要素seをParseで再び読み込める形式の文字列に変換する。シンボル名はstから取得する。
+func Format(se SyntaxElement, st *SymbolTable) (string, error) {
+	var b bytes.Buffer
+	if err := format(&b, se, st); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func format(b *bytes.Buffer, se SyntaxElement, st *SymbolTable) error {
+	if se == nil {
+		return ErrorArgumentIsNil
+	}
+	if lst, ok := se.(*List); ok {
+		b.WriteRune(lst.openchar)
+		for i, e := range lst.elements {
+			if i > 0 {
+				b.WriteRune(space)
+			}
+			if err := format(b, e, st); err != nil {
+				return err
+			}
+		}
+		b.WriteRune(closingParen(lst.openchar))
+		return nil
+	}
+	if sid, ok := se.SymbolValue(); ok {
+		n, err := st.GetSymbolName(sid)
+		if err != nil {
+			return err
+		}
+		b.WriteString(n)
+	} else if s, ok := se.StringValue(); ok {
+		b.WriteString(QuoteString(s))
+	} else if i, ok := se.IntValue(); ok {
+		b.WriteString(strconv.FormatInt(i, 10))
+	} else if f, ok := se.FloatValue(); ok {
+		b.WriteString(FormatFloat(f))
+	} else if d, ok := se.DurationValue(); ok {
+		b.WriteString(d.String())
+	} else {
+		return ErrorValueTypeIsNotAsExpected
+	}
+	return nil
+}
+
+func closingParen(open rune) rune {
+	switch open {
+	case leftSquareBracket:
+		return rightSquareBracket
+	case leftCurlyBracket:
+		return rightCurlyBracket
+	}
+	return rightParenthesis
+}
+
+// QuoteString 文字列sを文字列リテラルとして読み込める形式に変換する。
+func QuoteString(s string) string {
+	var b bytes.Buffer
+	b.WriteRune(doublequote)
+	for _, r := range s {
+		switch r {
+		case backslash, doublequote:
+			b.WriteRune(backslash)
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				// 制御文字は16進数のエスケープシーケンスで表す。
+				b.WriteString(`\x`)
+				b.WriteString(strconv.FormatInt(int64(r)>>4, 16))
+				b.WriteString(strconv.FormatInt(int64(r)&0xf, 16))
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteRune(doublequote)
+	return b.String()
+}
+
+// FormatFloat 浮動小数点数fを浮動小数点数リテラルとして読み込める形式に変換する。
+// 整数リテラルと区別できるように、必要に応じて小数点を付加する。
+func FormatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
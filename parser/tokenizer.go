@@ -143,6 +143,60 @@ func (ss *stokenizer) readString() (string, int, error) {
 	return "", nr, newError(ss.inputname, ss.line, ss.column, ErrorStringLiteralMustBeASingleLine, nil) // 文字列リテラルが行末で閉じられなかった
 }
 
+// readInterpolation 埋め込み式付き文字列リテラルの最初の'"'以降の部分を、エスケープシーケンスを解釈せずにそのまま返す。
+// 埋め込み式の中の文字列リテラルや波括弧は閉じるまで読み進める。
+func (ss *stokenizer) readInterpolation() (string, int, error) {
+	runes := make([]rune, 0)
+	nr := 0
+	depth := 0      // 埋め込み式の波括弧の深さ
+	open := 0       // 最も外側の埋め込み式の'{'のrunesでの位置
+	quoted := false // 埋め込み式の中の文字列リテラルを読んでいる場合はtrue
+	escaped := false
+	r, sz, err := ss.reader.ReadRune()
+	for sz > 0 && err == nil {
+		nr++
+		switch {
+		case escaped:
+			escaped = false
+		case r == backslash:
+			escaped = true
+		case quoted:
+			quoted = r != doublequote
+		case r == doublequote:
+			if depth == 0 {
+				return string(runes), nr, nil
+			}
+			quoted = true
+		case r == leftCurlyBracket:
+			if depth == 0 {
+				// "{{"は埋め込み式ではなく'{'そのものを表す。
+				next, nsz, nerr := ss.reader.ReadRune()
+				if nsz > 0 && nerr == nil && next == leftCurlyBracket {
+					runes = append(runes, r)
+					r = next
+					nr++
+					break
+				} else if nsz > 0 && nerr == nil {
+					if err = ss.reader.UnreadRune(); err != nil {
+						return "", nr, err
+					}
+				}
+				open = len(runes)
+			}
+			depth++
+		case r == rightCurlyBracket && depth > 0:
+			depth--
+		}
+		runes = append(runes, r)
+		r, sz, err = ss.reader.ReadRune()
+	}
+	if depth > 0 {
+		// 埋め込み式が閉じられないまま行末に達した場合は、その'{'の位置を示す。'f'と'"'の分を+2する。
+		return "", nr, newError(ss.inputname, ss.line, ss.column+2+open, ErrorInvalidInterpolation, string(runes[open:]))
+	}
+	return "", nr, newError(ss.inputname, ss.line, ss.column, ErrorStringLiteralMustBeASingleLine, nil) // 文字列リテラルが行末で閉じられなかった
+}
+
 func (ss *stokenizer) readSymbol() (string, int, error) {
 	rs := make([]rune, 0)
 	nr := 0
@@ -203,6 +257,8 @@ const (
 	symbol        = -(iota + 1)
 	stringLiteral = -(iota + 1)
 	commentText   = -(iota + 1)
+	// interpolatedString 埋め込み式付き文字列リテラル（f"..."）。トークンのテキストはエスケープシーケンスを解釈していない。
+	interpolatedString = -(iota + 1)
 )
 
 // scan 次のトークンを読み込む
@@ -246,15 +302,35 @@ func (ss *stokenizer) scan() (rune, int, int, error) {
 		}
 		return 0, ss.line, ss.column, err
 	default:
-		err = ss.reader.UnreadRune()
+		prefix := ""
+		if r == interpolationPrefix {
+			next, nsz, nerr := ss.reader.ReadRune()
+			if nsz > 0 && nerr == nil && next == doublequote {
+				sl, nr, err := ss.readInterpolation()
+				c := ss.column
+				ss.column = ss.column + 2 + nr // 'f'と'"'の分はss.readInterpolation()の返り値には含まれないので+2
+				if err == nil {
+					ss.lasttext = sl
+					return interpolatedString, ss.line, c, nil
+				}
+				return 0, ss.line, c, err
+			}
+			// 'f'で始まるシンボルは読み込んじゃった'f'を先頭に付け足す。
+			prefix = string(r)
+			if nsz > 0 && nerr == nil {
+				err = ss.reader.UnreadRune()
+			}
+		} else {
+			err = ss.reader.UnreadRune()
+		}
 		if err != nil {
 			return 0, ss.line, ss.column, err
 		}
 		sl, nr, err := ss.readSymbol()
 		c := ss.column
-		ss.column = ss.column + nr
+		ss.column = ss.column + len(prefix) + nr
 		if err == nil {
-			ss.lasttext = sl
+			ss.lasttext = prefix + sl
 			return symbol, ss.line, c, nil
 		}
 		return 0, ss.line, c, err
@@ -1,11 +1,15 @@
 package runtime
 
+import (
+	"github.com/healthy-tiger/scalc/parser"
+)
+
 const (
 	trueSymbol  = "true"
 	falseSymbol = "false"
 )
 
-// BoolToInt Goのbool型をscalcの内部表現の整数型に変換する。
+// BoolToInt Goのbool型を互換モードのscalcの内部表現の整数型に変換する。
 func BoolToInt(b bool) int64 {
 	if b {
 		return 1
@@ -13,10 +17,30 @@ func BoolToInt(b bool) int64 {
 	return 0
 }
 
+// BoolValue Goのbool型をnsの設定に応じたscalcの真偽値に変換する。互換モードの場合はint64の1または0になる。
+func BoolValue(b bool, ns *Namespace) interface{} {
+	if ns.Config().IntBool {
+		return BoolToInt(b)
+	}
+	return b
+}
+
+// isTrue 条件式の評価結果vを真偽値として解釈する。
+// 真偽値以外の値の場合はエラーを返す。ただし互換モードの場合はint64も受け付け、0以外を真とする。
+func isTrue(v interface{}, pos parser.Position, ns *Namespace) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case int64:
+		if ns.Config().IntBool {
+			return b != 0, nil
+		}
+	}
+	return false, NewEvalError(pos, ErrorOperantsMustBeOfBoolType, v)
+}
+
 // RegisterBoolType streeにbool型のシンボルを、nsにシンボルに対応する値を登録する。
 func RegisterBoolType(ns *Namespace) {
-	trueid := ns.GetSymbolID(trueSymbol)
-	falseid := ns.GetSymbolID(falseSymbol)
-	ns.Set(trueid, BoolToInt(true))
-	ns.Set(falseid, BoolToInt(false))
+	ns.RegisterConstant(trueSymbol, BoolValue(true, ns))
+	ns.RegisterConstant(falseSymbol, BoolValue(false, ns))
 }
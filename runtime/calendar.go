@@ -0,0 +1,385 @@
+package runtime
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	isBusinessDaySymbol       = "is-business-day"
+	addBusinessDaysSymbol     = "add-business-days"
+	businessDaysBetweenSymbol = "business-days-between"
+	nextBusinessDaySymbol     = "next-business-day"
+	holidayNameSymbol         = "holiday-name"
+)
+
+// 祝日の暦に関するエラーコード
+var (
+	ErrorUnknownHolidayCalendar    int
+	ErrorInvalidHolidayEntry       int
+	ErrorNoBusinessDay             int
+	ErrorBusinessDaySpanIsTooLarge int
+)
+
+func init() {
+	ErrorUnknownHolidayCalendar = RegisterEvalError("Unknown holiday calendar %v")
+	ErrorInvalidHolidayEntry = RegisterEvalError("Invalid holiday entry %v")
+	ErrorNoBusinessDay = RegisterEvalError("No business day found within %v days")
+	ErrorBusinessDaySpanIsTooLarge = RegisterEvalError("The span to count business days on a holiday calendar must be within %v days")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorUnknownHolidayCalendar, "祝日の暦 %v が見つかりません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidHolidayEntry, "祝日の定義 %v が不正です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorNoBusinessDay, "%v 日以内に営業日がありません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorBusinessDaySpanIsTooLarge, "祝日の暦で営業日を数える期間は %v 日以内でなければなりません")
+
+	RegisterHolidayCalendar(JapaneseHolidayCalendar, &japaneseHolidays{years: make(map[int]map[monthDay]string)})
+}
+
+// HolidayCalendar 祝日の暦。営業日の計算では土曜日、日曜日と暦の祝日を休日とする。
+type HolidayCalendar interface {
+	// Holiday 日付が祝日の場合は祝日の名前とtrueを返す。
+	Holiday(year int, month time.Month, day int) (string, bool)
+}
+
+var (
+	holidayCalendarsLock sync.RWMutex
+	// holidayCalendars 名前で指定できる祝日の暦
+	holidayCalendars = make(map[string]HolidayCalendar)
+)
+
+// RegisterHolidayCalendar 祝日の暦calをnameという名前で登録する。同じ名前の暦がすでにあれば置き換える。
+func RegisterHolidayCalendar(name string, cal HolidayCalendar) {
+	holidayCalendarsLock.Lock()
+	defer holidayCalendarsLock.Unlock()
+	holidayCalendars[name] = cal
+}
+
+// LookupHolidayCalendar nameという名前で登録された祝日の暦を返す。
+func LookupHolidayCalendar(name string) (HolidayCalendar, bool) {
+	holidayCalendarsLock.RLock()
+	defer holidayCalendarsLock.RUnlock()
+	cal, ok := holidayCalendars[name]
+	return cal, ok
+}
+
+// holidayFile ファイルから読み込んだ祝日の暦
+type holidayFile struct {
+	dates    map[string]string   // 年月日（YYYY-MM-DD）ごとの祝日
+	annual   map[monthDay]string // 毎年の祝日
+	includes []HolidayCalendar   // 取り込んだ暦
+}
+
+// Holiday 日付が祝日の場合は名前とtrueを返す。年月日の指定、毎年の指定、取り込んだ暦の順に調べる。
+func (h *holidayFile) Holiday(year int, month time.Month, day int) (string, bool) {
+	if name, ok := h.dates[time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")]; ok {
+		return name, true
+	}
+	if name, ok := h.annual[monthDay{month, day}]; ok {
+		return name, true
+	}
+	for _, cal := range h.includes {
+		if name, ok := cal.Holiday(year, month, day); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ParseHolidayCalendar rから祝日の暦を読み込む。filenameはエラーの位置の表示に使う。
+// 一行に一つ、次のいずれかを書く。空行と'#'で始まる行は無視する。
+//
+//	2024-08-13 夏季休業   （その年月日だけの祝日）
+//	12-31 年末休業        （毎年の祝日）
+//	include jp           （登録済みの暦の祝日をすべて含める）
+func ParseHolidayCalendar(filename string, r io.Reader) (HolidayCalendar, error) {
+	h := &holidayFile{make(map[string]string), make(map[monthDay]string), make([]HolidayCalendar, 0)}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		pos := parser.Position{Filename: filename, Line: line, Column: 1}
+		fields := strings.Fields(text)
+		name := strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+		if fields[0] == "include" {
+			cal, ok := LookupHolidayCalendar(name)
+			if !ok {
+				return nil, NewEvalError(pos, ErrorUnknownHolidayCalendar, name)
+			}
+			h.includes = append(h.includes, cal)
+			continue
+		}
+		if name == "" {
+			name = fields[0]
+		}
+		if d, err := time.Parse("2006-01-02", fields[0]); err == nil {
+			h.dates[d.Format("2006-01-02")] = name
+		} else if d, err := time.Parse("01-02", fields[0]); err == nil {
+			h.annual[monthDay{d.Month(), d.Day()}] = name
+		} else {
+			return nil, NewEvalError(pos, ErrorInvalidHolidayEntry, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// LoadHolidayCalendar ファイルpathから祝日の暦を読み込み、nameという名前で登録する。
+func LoadHolidayCalendar(name string, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cal, err := ParseHolidayCalendar(path, f)
+	if err != nil {
+		return err
+	}
+	RegisterHolidayCalendar(name, cal)
+	return nil
+}
+
+// maxHolidayRun 営業日を探すときに続けて調べる休日の日数の上限。すべての日が休日の暦で無限に探し続けないようにする。
+const maxHolidayRun = 366
+
+// maxBusinessDaySpan 祝日の暦で営業日を数えるときに一日ずつ調べる期間の日数の上限（約100年）。
+// 土曜日と日曜日だけを休日とする場合は日数を計算で求めるので、この上限はない。
+const maxBusinessDaySpan = 36525
+
+// maxBusinessDays add-business-daysで進められる営業日の数の上限。時刻として表せる範囲を超えないようにする。
+const maxBusinessDays = 1 << 40
+
+// weekdaysBefore 1970年1月1日からの日数がn未満の日のうち、月曜日から金曜日までの日の数を返す（nが負の場合は負の数）。
+func weekdaysBefore(n int64) int64 {
+	// 1970年1月1日は木曜日なので、木曜日から始まる週ごとに数える。
+	q, r := n/7, n%7
+	if r < 0 {
+		q, r = q-1, r+7
+	}
+	return 5*q + [7]int64{0, 1, 2, 2, 2, 3, 4}[r]
+}
+
+// businessDays 祝日の暦とタイムゾーンに従って営業日を数える。calがnilの場合は土曜日と日曜日だけを休日とする。
+type businessDays struct {
+	cal HolidayCalendar
+	loc *time.Location
+}
+
+// holiday 時刻tのタイムゾーンでの日付が祝日の場合は名前とtrueを返す。
+func (b *businessDays) holiday(t time.Time) (string, bool) {
+	if b.cal == nil {
+		return "", false
+	}
+	y, m, d := t.In(b.loc).Date()
+	return b.cal.Holiday(y, m, d)
+}
+
+// isBusinessDay 時刻tのタイムゾーンでの日付が営業日の場合はtrueを返す。
+func (b *businessDays) isBusinessDay(t time.Time) bool {
+	wd := t.In(b.loc).Weekday()
+	if wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, ok := b.holiday(t)
+	return !ok
+}
+
+// step 時刻tから日付をdir（1または-1）の向きに進め、最初の営業日の同じ時刻を返す。
+func (b *businessDays) step(t time.Time, dir int, pos parser.Position) (time.Time, error) {
+	for i := 1; i <= maxHolidayRun; i++ {
+		if d := addDate(t, 0, 0, dir*i); b.isBusinessDay(d) {
+			return d, nil
+		}
+	}
+	return time.Time{}, NewEvalError(pos, ErrorNoBusinessDay, maxHolidayRun)
+}
+
+// evalBusinessDays (関数名 時刻 ... [祝日の暦 [タイムゾーン]])の形式のlstのindex番目以降を評価し、営業日の数え方を返す。
+// 祝日の暦を省略した場合は設定のCalendarを使い、空文字列の場合は土曜日と日曜日だけを休日とする。
+func evalBusinessDays(lst *parser.List, index int, ns *Namespace) (*businessDays, error) {
+	name := ns.Config().Calendar
+	if lst.Len() > index {
+		var err error
+		name, err = EvalAsString(lst.ElementAt(index), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b := &businessDays{}
+	if name != "" {
+		cal, ok := LookupHolidayCalendar(name)
+		if !ok {
+			pos := lst.Position()
+			if lst.Len() > index {
+				pos = lst.ElementAt(index).Position()
+			}
+			return nil, NewEvalError(pos, ErrorUnknownHolidayCalendar, name)
+		}
+		b.cal = cal
+	}
+	loc, err := evalLocation(lst, index+1, ns)
+	if err != nil {
+		return nil, err
+	}
+	b.loc = loc
+	return b, nil
+}
+
+// evalBusinessDayArgs (関数名 時刻 [祝日の暦 [タイムゾーン]])の形式のlstを評価し、時刻と営業日の数え方を返す。
+func evalBusinessDayArgs(lst *parser.List, ns *Namespace) (time.Time, *businessDays, error) {
+	if lst.Len() < 2 || lst.Len() > 4 {
+		return time.Time{}, nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	b, err := evalBusinessDays(lst, 2, ns)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return t.In(b.loc), b, nil
+}
+
+// isBusinessDayBody (is-business-day 時刻 [祝日の暦 [タイムゾーン]])
+// 時刻の日付が土曜日、日曜日、祝日のいずれでもない場合に真を返す。
+func isBusinessDayBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	t, b, err := evalBusinessDayArgs(lst, ns)
+	if err != nil {
+		return nil, err
+	}
+	return BoolValue(b.isBusinessDay(t), ns), nil
+}
+
+// holidayNameBody (holiday-name 時刻 [祝日の暦 [タイムゾーン]])
+// 時刻の日付が祝日の場合は祝日の名前を、そうでない場合はnilを返す。
+func holidayNameBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	t, b, err := evalBusinessDayArgs(lst, ns)
+	if err != nil {
+		return nil, err
+	}
+	if name, ok := b.holiday(t); ok {
+		return name, nil
+	}
+	return nil, nil
+}
+
+// nextBusinessDayBody (next-business-day 時刻 [祝日の暦 [タイムゾーン]])
+// 時刻の翌日以降で最初の営業日の、同じ時刻のUNIX時間を返す。
+func nextBusinessDayBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	t, b, err := evalBusinessDayArgs(lst, ns)
+	if err != nil {
+		return nil, err
+	}
+	next, err := b.step(t, 1, lst.Position())
+	if err != nil {
+		return nil, err
+	}
+	return timeValue(next), nil
+}
+
+// addBusinessDaysBody (add-business-days 時刻 日数 [祝日の暦 [タイムゾーン]])
+// 時刻から営業日を日数だけ進めた日の、同じ時刻のUNIX時間を返す。日数が負の場合は遡る。日数が0の場合は時刻をそのまま返す。
+func addBusinessDaysBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 || lst.Len() > 5 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 4)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	n, err := EvalAsInt(lst.ElementAt(2), ns)
+	if err != nil {
+		return nil, err
+	}
+	b, err := evalBusinessDays(lst, 3, ns)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBusinessDays || n < -maxBusinessDays {
+		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorValueOutOfRange, n, -maxBusinessDays, maxBusinessDays)
+	}
+	dir := 1
+	if n < 0 {
+		dir, n = -1, -n
+	}
+	start := t.In(b.loc)
+	t = start
+	if b.cal == nil && n > 5 {
+		// 7日ごとに営業日はちょうど5日あるので、週単位で進めてから残りを一日ずつ進める。
+		weeks := (n - 1) / 5
+		t = addDate(t, 0, 0, dir*int(weeks*7))
+		n -= weeks * 5
+	}
+	for ; n > 0; n-- {
+		t, err = b.step(t, dir, lst.Position())
+		if err != nil {
+			return nil, err
+		}
+		if span := civilDays(t) - civilDays(start); b.cal != nil && (span > maxBusinessDaySpan || span < -maxBusinessDaySpan) {
+			return nil, NewEvalError(lst.Position(), ErrorBusinessDaySpanIsTooLarge, maxBusinessDaySpan)
+		}
+	}
+	return timeValue(t), nil
+}
+
+// businessDaysBetweenBody (business-days-between 時刻1 時刻2 [祝日の暦 [タイムゾーン]])
+// 時刻1の日付の翌日から時刻2の日付までの営業日の数を返す。時刻2が時刻1より前の場合は負の数を返す。
+func businessDaysBetweenBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 || lst.Len() > 5 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 4)
+	}
+	t1, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	t2, err := evalAsTime(lst.ElementAt(2), ns)
+	if err != nil {
+		return nil, err
+	}
+	b, err := evalBusinessDays(lst, 3, ns)
+	if err != nil {
+		return nil, err
+	}
+	// 日付だけを比べるので、1970年1月1日からの日数で数える。
+	start, end := civilDays(t1.In(b.loc)), civilDays(t2.In(b.loc))
+	sign := int64(1)
+	if end < start {
+		start, end, sign = end, start, -1
+	}
+	count := weekdaysBefore(end+1) - weekdaysBefore(start+1)
+	if b.cal != nil {
+		if end-start > maxBusinessDaySpan {
+			return nil, NewEvalError(lst.Position(), ErrorBusinessDaySpanIsTooLarge, maxBusinessDaySpan)
+		}
+		// 月曜日から金曜日までの祝日を除く。
+		for n := start + 1; n <= end; n++ {
+			d := time.Unix(n*24*60*60, 0).UTC()
+			if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+				continue
+			}
+			if _, ok := b.cal.Holiday(d.Date()); ok {
+				count--
+			}
+		}
+	}
+	return sign * count, nil
+}
+
+// RegisterCalendar 祝日の暦と営業日に関する拡張関数を登録する。
+func RegisterCalendar(ns *Namespace) {
+	ns.RegisterExtension(isBusinessDaySymbol, nil, isBusinessDayBody)
+	ns.RegisterExtension(addBusinessDaysSymbol, nil, addBusinessDaysBody)
+	ns.RegisterExtension(businessDaysBetweenSymbol, nil, businessDaysBetweenBody)
+	ns.RegisterExtension(nextBusinessDaySymbol, nil, nextBusinessDayBody)
+	ns.RegisterExtension(holidayNameSymbol, nil, holidayNameBody)
+}
@@ -0,0 +1,75 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	withNowSymbol      = "with-now"
+	withTimeZoneSymbol = "with-time-zone"
+)
+
+// Now 現在時刻を返す。with-nowの評価中は固定した時刻を、そうでなければ設定のClock（nilの場合はtime.Now）の時刻を返す。
+func (ns *Namespace) Now() time.Time {
+	if r := ns.Root(); r.clock != nil {
+		return r.clock()
+	}
+	if c := ns.Config().Clock; c != nil {
+		return c()
+	}
+	return time.Now()
+}
+
+// Location タイムゾーンを省略した場合のタイムゾーンを返す。with-time-zoneの評価中は指定したタイムゾーンを、
+// そうでなければ設定のLocation（nilの場合はtime.Local）を返す。
+func (ns *Namespace) Location() *time.Location {
+	if r := ns.Root(); r.location != nil {
+		return r.location
+	}
+	if loc := ns.Config().Location; loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// withNowBody (with-now 時刻 式 ...)
+// 式を順に評価し、最後の式の評価結果を返す。評価中はnowが常に時刻を返す。式から呼び出した関数の中でも同じ。
+func withNowBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	r := ns.Root()
+	saved := r.clock
+	r.clock = func() time.Time { return t }
+	defer func() { r.clock = saved }()
+	return evalSequence(lst, 2, ns)
+}
+
+// withTimeZoneBody (with-time-zone タイムゾーン 式 ...)
+// 式を順に評価し、最後の式の評価結果を返す。評価中はタイムゾーンを省略した時刻の関数がそのタイムゾーンを使う。
+func withTimeZoneBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	loc, err := evalLocation(lst, 1, ns)
+	if err != nil {
+		return nil, err
+	}
+	r := ns.Root()
+	saved := r.location
+	r.location = loc
+	defer func() { r.location = saved }()
+	return evalSequence(lst, 2, ns)
+}
+
+// RegisterClock 現在時刻とタイムゾーンを一時的に差し替える拡張関数を登録する。
+func RegisterClock(ns *Namespace) {
+	ns.RegisterExtension(withNowSymbol, nil, withNowBody)
+	ns.RegisterExtension(withTimeZoneSymbol, nil, withTimeZoneBody)
+}
@@ -0,0 +1,162 @@
+package runtime
+
+import (
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	condSymbol   = "cond"
+	caseSymbol   = "case"
+	switchSymbol = "switch"
+	whenSymbol   = "when"
+	unlessSymbol = "unless"
+	elseSymbol   = "else"
+)
+
+// 条件分岐に関するエラーコード
+var (
+	ErrorAClauseMustBeANonEmptyList       int
+	ErrorTheElseClauseMustBeTheLastClause int
+)
+
+func init() {
+	ErrorAClauseMustBeANonEmptyList = RegisterEvalError("A clause must be a non-empty list.")
+	ErrorTheElseClauseMustBeTheLastClause = RegisterEvalError("The else clause must be the last clause.")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorAClauseMustBeANonEmptyList, "節は空でないリストでなければなりません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheElseClauseMustBeTheLastClause, "else節は最後の節でなければなりません。")
+}
+
+// clauseAt lstのindex番目の要素を節として取り出す。elseで始まる節の場合はisElseがtrueになる。
+func clauseAt(lst *parser.List, index int, ns *Namespace) (clause *parser.List, isElse bool, err error) {
+	e := lst.ElementAt(index)
+	if !e.IsList() || e.(*parser.List).Len() == 0 {
+		return nil, false, NewEvalError(e.Position(), ErrorAClauseMustBeANonEmptyList)
+	}
+	clause = e.(*parser.List)
+	if sid, ok := clause.SymbolAt(0); ok && sid == ns.GetSymbolID(elseSymbol) {
+		if index != lst.Len()-1 {
+			return nil, true, NewEvalError(e.Position(), ErrorTheElseClauseMustBeTheLastClause)
+		}
+		isElse = true
+	}
+	return clause, isElse, nil
+}
+
+// condBody (cond (条件 式 ...) ... (else 式 ...))
+// 条件が真になった最初の節の式を順に評価し、最後の式の評価結果を返す。式のない節は条件の評価結果を返す。
+// どの節も選ばれなかった場合はfalseを返す。
+func condBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
+	}
+	for i := 1; i < lst.Len(); i++ {
+		clause, isElse, err := clauseAt(lst, i, ns)
+		if err != nil {
+			return nil, err
+		}
+		if isElse {
+			return evalSequence(clause, 1, ns)
+		}
+		c, err := EvalElement(clause.ElementAt(0), ns)
+		if err != nil {
+			return nil, err
+		}
+		t, err := isTrue(c, clause.ElementAt(0).Position(), ns)
+		if err != nil {
+			return nil, err
+		}
+		if t {
+			if clause.Len() == 1 {
+				return c, nil
+			}
+			return evalSequence(clause, 1, ns)
+		}
+	}
+	return BoolValue(false, ns), nil
+}
+
+// matchesKey 節の先頭の要素eがkeyに一致する場合にtrueを返す。
+// eがリストの場合はその要素のいずれかに一致すればよい。リストの要素とリスト以外のeは評価してから比較する。
+func matchesKey(key interface{}, e parser.SyntaxElement, ns *Namespace) (bool, error) {
+	if !e.IsList() {
+		v, err := EvalElement(e, ns)
+		if err != nil {
+			return false, err
+		}
+		return valuesEqual(key, v), nil
+	}
+	l := e.(*parser.List)
+	for i := 0; i < l.Len(); i++ {
+		if ok, err := matchesKey(key, l.ElementAt(i), ns); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// caseBody (case キー (値 式 ...) ((値 値 ...) 式 ...) ... (else 式 ...))
+// キーと型と値が一致する値を持つ最初の節の式を順に評価し、最後の式の評価結果を返す。
+// どの節も選ばれなかった場合はfalseを返す。
+func caseBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	key, err := EvalElement(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	for i := 2; i < lst.Len(); i++ {
+		clause, isElse, err := clauseAt(lst, i, ns)
+		if err != nil {
+			return nil, err
+		}
+		if isElse {
+			return evalSequence(clause, 1, ns)
+		}
+		ok, err := matchesKey(key, clause.ElementAt(0), ns)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return evalSequence(clause, 1, ns)
+		}
+	}
+	return BoolValue(false, ns), nil
+}
+
+// evalWhen 条件の評価結果がexpectedと一致する場合に残りの式を順に評価し、最後の式の評価結果を返す。一致しない場合はfalseを返す。
+func evalWhen(lst *parser.List, ns *Namespace, expected bool) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	c, err := EvalElement(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	t, err := isTrue(c, lst.ElementAt(1).Position(), ns)
+	if err != nil {
+		return nil, err
+	}
+	if t != expected {
+		return BoolValue(false, ns), nil
+	}
+	return evalSequence(lst, 2, ns)
+}
+
+func whenBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalWhen(lst, ns, true)
+}
+
+func unlessBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalWhen(lst, ns, false)
+}
+
+// RegisterConditional 条件分岐に関する拡張関数を登録する。
+func RegisterConditional(ns *Namespace) {
+	ns.RegisterExtension(condSymbol, nil, condBody)
+	ns.RegisterExtension(caseSymbol, nil, caseBody)
+	ns.RegisterExtension(switchSymbol, nil, caseBody)
+	ns.RegisterExtension(whenSymbol, nil, whenBody)
+	ns.RegisterExtension(unlessSymbol, nil, unlessBody)
+}
@@ -0,0 +1,34 @@
+package runtime
+
+import (
+	"time"
+)
+
+// 文字列の照合順序
+const (
+	CollationLexical = iota // コードポイント順
+	CollationNatural        // 数字の並びを数値として比較する自然順（str-cmp-naturalと同じ順序）
+)
+
+// Config インタプリタごとの設定。ルートの名前空間が保持し、その子の名前空間で共有する。
+type Config struct {
+	// IntBool trueの場合は旧バージョンとの互換モードとして、真偽値をint64の1と0で表し、条件式にint64を受け付ける。
+	// MakeDefaultNamespace（RegisterBoolType）の呼び出し前に設定すること。
+	IntBool bool
+	// NumericPromotion trueの場合は算術演算と比較で整数を浮動小数点数に昇格させ、(+ 1 2.5)のような型の混在を許す。
+	// falseの場合はオペラントの型が一致しなければエラーにする。
+	NumericPromotion bool
+	// Collation <、<=、>、>=で文字列を比較する際の照合順序
+	Collation int
+	// Calendar is-business-dayなどで祝日の暦を省略した場合に使う暦の名前。空文字列の場合は土曜日と日曜日だけを休日とする。
+	Calendar string
+	// Clock nowが返す現在時刻を与える関数。nilの場合はtime.Nowを使う。
+	Clock func() time.Time
+	// Location 時刻の関数でタイムゾーンを省略した場合と"Local"を指定した場合のタイムゾーン。nilの場合はtime.Localを使う。
+	Location *time.Location
+}
+
+// Config nsのルートの名前空間の設定を返す。
+func (ns *Namespace) Config() *Config {
+	return ns.Root().config
+}
@@ -0,0 +1,483 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	durationSymbol     = "duration"
+	durationInSymbol   = "duration-in"
+	isDurationSymbol   = "is-duration"
+	timeAddSymbol      = "time-add"
+	addDateSymbol      = "add-date"
+	timeDiffSymbol     = "time-diff"
+	timeTruncateSymbol = "time-truncate"
+	timeRoundSymbol    = "time-round"
+)
+
+// 時間の長さに関するエラーコード
+var (
+	ErrorInvalidDuration    int
+	ErrorInvalidTimeUnit    int
+	ErrorDurationOutOfRange int
+)
+
+func init() {
+	ErrorInvalidDuration = RegisterEvalError("Invalid duration %v")
+	ErrorInvalidTimeUnit = RegisterEvalError("Invalid time unit %v")
+	ErrorDurationOutOfRange = RegisterEvalError("Duration out of range: %v")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidDuration, "時間の長さ %v が不正です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidTimeUnit, "時間の単位 %v が不正です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorDurationOutOfRange, "時間の長さが範囲外です: %v")
+}
+
+// 暦に従って長さが変わる時間の単位
+const (
+	unitDay   = "day"
+	unitWeek  = "week"
+	unitMonth = "month"
+	unitYear  = "year"
+)
+
+// fixedTimeUnits 長さが一定の時間の単位
+var fixedTimeUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// durationUnit durationとduration-inで使える時間の単位unitの長さを返す。dayとweekはそれぞれ24時間と7日とする。
+func durationUnit(unit string) (time.Duration, bool) {
+	switch unit {
+	case unitDay:
+		return 24 * time.Hour, true
+	case unitWeek:
+		return 7 * 24 * time.Hour, true
+	}
+	d, ok := fixedTimeUnits[unit]
+	return d, ok
+}
+
+// isCalendarUnit unitが暦に従って長さが変わる時間の単位の場合はtrueを返す。
+func isCalendarUnit(unit string) bool {
+	return unit == unitDay || unit == unitWeek || unit == unitMonth || unit == unitYear
+}
+
+// evalTimeUnit lstのindex番目の要素を評価して、長さが一定の単位または暦の単位として返す。
+func evalTimeUnit(lst *parser.List, index int, ns *Namespace) (string, error) {
+	unit, err := EvalAsString(lst.ElementAt(index), ns)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := fixedTimeUnits[unit]; ok || isCalendarUnit(unit) {
+		return unit, nil
+	}
+	return "", NewEvalError(lst.ElementAt(index).Position(), ErrorInvalidTimeUnit, unit)
+}
+
+// toTime UNIX時間のvを時刻に変換する。vがfloat64の場合は小数部をマイクロ秒の精度で扱う。
+func toTime(v interface{}, pos parser.Position) (time.Time, error) {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0), nil
+	case float64:
+		if math.IsNaN(t) || t < math.MinInt64 || t >= math.MaxInt64 {
+			return time.Time{}, NewEvalError(pos, ErrorValueOutOfRange, t, int64(math.MinInt64), int64(math.MaxInt64))
+		}
+		sec := math.Floor(t)
+		// float64のUNIX時間はマイクロ秒程度の精度しかないので、それより細かい部分は丸める。
+		usec := math.Round((t - sec) * 1e6)
+		return time.Unix(int64(sec), int64(usec)*1000), nil
+	}
+	return time.Time{}, NewEvalError(pos, ErrorOperantsMustBeNumeric, v)
+}
+
+// timeValue 時刻tをUNIX時間に変換する。秒未満の部分がある場合はfloat64、ない場合はint64を返す。
+func timeValue(t time.Time) interface{} {
+	if t.Nanosecond() == 0 {
+		return t.Unix()
+	}
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+// evalAsTime 名前空間nsでelmを評価し、その結果のUNIX時間を時刻として返す。
+func evalAsTime(elm parser.SyntaxElement, ns *Namespace) (time.Time, error) {
+	v, err := EvalElement(elm, ns)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return toTime(v, elm.Position())
+}
+
+// evalAsDuration 名前空間nsでelmを評価し、その結果を時間の長さとして返す。時間の長さでない結果の場合はエラーを返す。
+func evalAsDuration(elm parser.SyntaxElement, ns *Namespace) (time.Duration, error) {
+	v, err := EvalElement(elm, ns)
+	if err != nil {
+		return 0, err
+	}
+	d, ok := v.(time.Duration)
+	if !ok {
+		return 0, NewEvalError(elm.Position(), ErrorInvalidDuration, v)
+	}
+	return d, nil
+}
+
+// durationBody (duration 文字列) または (duration 数値 [単位])
+// "1h30m"のような文字列、または数値と単位（省略時は秒）から時間の長さを作る。単位にはdayとweekも使え、それぞれ24時間と7日とする。
+func durationBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 && lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	v, err := EvalElement(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := v.(string); ok && lst.Len() == 2 {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorInvalidDuration, s)
+		}
+		return d, nil
+	}
+	unit, u := time.Second, "s"
+	if lst.Len() == 3 {
+		u, err = EvalAsString(lst.ElementAt(2), ns)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if unit, ok = durationUnit(u); !ok {
+			return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorInvalidTimeUnit, u)
+		}
+	}
+	// time.Durationで表せない長さは、符号が反転したりしないようにエラーにする。
+	switch n := v.(type) {
+	case int64:
+		if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+			return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorDurationOutOfRange, fmt.Sprint(n, " ", u))
+		}
+		return time.Duration(n) * unit, nil
+	case float64:
+		f := math.Round(n * float64(unit))
+		if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorDurationOutOfRange, fmt.Sprint(n, " ", u))
+		}
+		return time.Duration(f), nil
+	}
+	return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorOperantsMustBeNumeric, v)
+}
+
+// durationInBody (duration-in 時間の長さ 単位)
+// 時間の長さを単位で表した浮動小数点数を返す。単位はdurationと同じく、dayとweekも使える。
+func durationInBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	d, err := evalAsDuration(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	u, err := EvalAsString(lst.ElementAt(2), ns)
+	if err != nil {
+		return nil, err
+	}
+	unit, ok := durationUnit(u)
+	if !ok {
+		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorInvalidTimeUnit, u)
+	}
+	return float64(d) / float64(unit), nil
+}
+
+// isDurationBody 引数がすべて時間の長さの場合に真を返す。
+func isDurationBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	params := make([]interface{}, lst.Len())
+	for i := 1; i < lst.Len(); i++ {
+		p, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = p
+	}
+
+	for i := 1; i < lst.Len(); i++ {
+		if _, ok := params[i].(time.Duration); !ok {
+			return BoolValue(false, ns), nil
+		}
+	}
+	return BoolValue(true, ns), nil
+}
+
+// timeOutOfRange 時刻の計算結果のUNIX時間（の見積もり）vがint64で表せない場合のエラーを返す。
+func timeOutOfRange(pos parser.Position, v float64) error {
+	return NewEvalError(pos, ErrorValueOutOfRange, v, int64(math.MinInt64), int64(math.MaxInt64))
+}
+
+// timeAddBody (time-add 時刻 時間の長さ ...)
+// 時刻に時間の長さを足したUNIX時間を返す。結果がint64のUNIX時間で表せない場合はエラーにする。
+func timeAddBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	for i := 2; i < lst.Len(); i++ {
+		d, err := evalAsDuration(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		next := t.Add(d)
+		if (d > 0 && next.Unix() < t.Unix()) || (d < 0 && next.Unix() > t.Unix()) {
+			return nil, timeOutOfRange(lst.ElementAt(i).Position(), float64(t.Unix())+d.Seconds())
+		}
+		t = next
+	}
+	return timeValue(t), nil
+}
+
+// addDateBody (add-date 時刻 年 月 日 [タイムゾーン])
+// 時刻に年数、月数、日数を足したUNIX時間を返す。月末を超える日付は翌月に繰り越さず、その月の末日にする。
+// 日数はタイムゾーンの暦で数えるので、夏時間の切り替えがあっても時刻は変わらない。結果がint64のUNIX時間で表せない場合はエラーにする。
+func addDateBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 5 && lst.Len() != 6 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 5)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	var ymd [3]int64
+	for i := range ymd {
+		ymd[i], err = EvalAsInt(lst.ElementAt(i+2), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	loc, err := evalLocation(lst, 5, ns)
+	if err != nil {
+		return nil, err
+	}
+	r := addDate(t.In(loc), int(ymd[0]), int(ymd[1]), int(ymd[2]))
+	// time.Dateは範囲を超えた年月日を黙って桁あふれさせるので、平均の長さで見積もった結果から大きく外れた場合はエラーにする。
+	// 見積もりとの差は月の長さの違いと月末への切り詰めによる数日なので、1年を超えることはない。
+	approx := float64(t.Unix()) + (float64(ymd[0])*12+float64(ymd[1]))*averageMonthSeconds + float64(ymd[2])*24*60*60
+	if math.Abs(approx-float64(r.Unix())) > 366*24*60*60 {
+		return nil, timeOutOfRange(lst.Position(), approx)
+	}
+	return timeValue(r), nil
+}
+
+// averageMonthSeconds グレゴリオ暦の1か月の平均の長さ（秒）
+const averageMonthSeconds = 365.2425 * 24 * 60 * 60 / 12
+
+// addDate 時刻tに年数、月数、日数を足した時刻を返す。time.AddDateと異なり、月末を超える日付はその月の末日にする。
+func addDate(t time.Time, years int, months int, days int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y+years, m+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1+days)
+}
+
+// civilDays 時刻tのタイムゾーンでの日付の、1970年1月1日からの日数を返す。
+func civilDays(t time.Time) int64 {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60)
+}
+
+// calendarDiff 時刻startからendまでの暦の単位unitの数を0に向かって切り捨てて返す。
+// 数は時刻の差ではなく年月日から見積もるので、time.Durationで表せない期間でも数えられる。
+func calendarDiff(end time.Time, start time.Time, unit string) int64 {
+	if end.Before(start) {
+		return -calendarDiff(start, end, unit)
+	}
+	var step func(n int) time.Time
+	var n int
+	switch unit {
+	case unitDay, unitWeek:
+		step = func(n int) time.Time { return start.AddDate(0, 0, n) }
+		n = int(civilDays(end) - civilDays(start))
+	default:
+		step = func(n int) time.Time { return addDate(start, 0, n, 0) }
+		ey, em, _ := end.Date()
+		sy, sm, _ := start.Date()
+		n = (ey-sy)*12 + int(em-sm)
+	}
+	// 見積もった数から、startに足してもendを超えない最大の数に合わせる。見積もりとの差は高々1になる。
+	for n > 0 && step(n).After(end) {
+		n--
+	}
+	for !step(n + 1).After(end) {
+		n++
+	}
+	switch unit {
+	case unitWeek:
+		n /= 7
+	case unitYear:
+		n /= 12
+	}
+	return int64(n)
+}
+
+// timeDiffBody (time-diff 時刻1 時刻2 [単位 [タイムゾーン]])
+// 時刻1から時刻2を引いた時間の長さを返す。単位を指定した場合は、その単位の数を0に向かって切り捨てた整数を返す。
+// day、week、month、yearはタイムゾーンの暦で数える。
+func timeDiffBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 || lst.Len() > 5 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 4)
+	}
+	t1, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	t2, err := evalAsTime(lst.ElementAt(2), ns)
+	if err != nil {
+		return nil, err
+	}
+	unit := ""
+	if lst.Len() > 3 {
+		unit, err = evalTimeUnit(lst, 3, ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	loc, err := evalLocation(lst, 4, ns)
+	if err != nil {
+		return nil, err
+	}
+	if isCalendarUnit(unit) {
+		return calendarDiff(t1.In(loc), t2.In(loc), unit), nil
+	}
+	// time.Subは差がtime.Durationで表せない場合に最大値または最小値を返すので、その場合は秒単位で数える。
+	d := t1.Sub(t2)
+	if t2.Add(d).Equal(t1) {
+		if unit == "" {
+			return d, nil
+		}
+		return int64(d / fixedTimeUnits[unit]), nil
+	}
+	sec, ok := secondsDiff(t1, t2)
+	if !ok || unit == "" || fixedTimeUnits[unit] < time.Second {
+		return nil, NewEvalError(lst.Position(), ErrorDurationOutOfRange, t1.UTC().Format(time.RFC3339)+" - "+t2.UTC().Format(time.RFC3339))
+	}
+	return sec / int64(fixedTimeUnits[unit]/time.Second), nil
+}
+
+// secondsDiff 時刻t1からt2を引いた秒数を0に向かって切り捨てて返す。差がint64で表せない場合はfalseを返す。
+func secondsDiff(t1 time.Time, t2 time.Time) (int64, bool) {
+	s1, s2 := t1.Unix(), t2.Unix()
+	sec := s1 - s2
+	if (s2 < 0 && sec < s1) || (s2 > 0 && sec > s1) {
+		return 0, false
+	}
+	if ns := t1.Nanosecond() - t2.Nanosecond(); ns < 0 && sec > 0 {
+		sec--
+	} else if ns > 0 && sec < 0 {
+		sec++
+	}
+	return sec, true
+}
+
+// truncateTime 時刻tを単位unitの区切りに切り捨てる。週の区切りは月曜日とする。
+func truncateTime(t time.Time, unit string) time.Time {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch unit {
+	case unitYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+	case unitMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case unitWeek:
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case unitDay:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case "h":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case "m":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case "s":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+	return t.Truncate(fixedTimeUnits[unit])
+}
+
+// nextTime 単位unitの区切りの時刻tの次の区切りを返す。
+func nextTime(t time.Time, unit string) time.Time {
+	switch unit {
+	case unitYear:
+		return t.AddDate(1, 0, 0)
+	case unitMonth:
+		return t.AddDate(0, 1, 0)
+	case unitWeek:
+		return t.AddDate(0, 0, 7)
+	case unitDay:
+		return t.AddDate(0, 0, 1)
+	}
+	return t.Add(fixedTimeUnits[unit])
+}
+
+// evalTruncateArgs (関数名 時刻 単位 [タイムゾーン])の形式のlstを評価し、切り捨てた時刻と元の時刻を返す。
+func evalTruncateArgs(lst *parser.List, ns *Namespace) (time.Time, time.Time, string, error) {
+	if lst.Len() != 3 && lst.Len() != 4 {
+		return time.Time{}, time.Time{}, "", NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	unit, err := evalTimeUnit(lst, 2, ns)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	loc, err := evalLocation(lst, 3, ns)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	t = t.In(loc)
+	return truncateTime(t, unit), t, unit, nil
+}
+
+// timeTruncateBody (time-truncate 時刻 単位 [タイムゾーン])
+// 時刻をタイムゾーンの暦で単位の区切りに切り捨てたUNIX時間を返す。
+func timeTruncateBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	tr, _, _, err := evalTruncateArgs(lst, ns)
+	if err != nil {
+		return nil, err
+	}
+	return timeValue(tr), nil
+}
+
+// timeRoundBody (time-round 時刻 単位 [タイムゾーン])
+// 時刻をタイムゾーンの暦で最も近い単位の区切りに丸めたUNIX時間を返す。ちょうど中間の場合は後の区切りにする。
+func timeRoundBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	tr, t, unit, err := evalTruncateArgs(lst, ns)
+	if err != nil {
+		return nil, err
+	}
+	if next := nextTime(tr, unit); next.Sub(t) <= t.Sub(tr) {
+		return timeValue(next), nil
+	}
+	return timeValue(tr), nil
+}
+
+// RegisterDuration 時間の長さと暦の計算に関する拡張関数を登録する。
+func RegisterDuration(ns *Namespace) {
+	ns.RegisterExtension(durationSymbol, nil, durationBody)
+	ns.RegisterExtension(durationInSymbol, nil, durationInBody)
+	ns.RegisterExtension(isDurationSymbol, nil, isDurationBody)
+	ns.RegisterExtension(timeAddSymbol, nil, timeAddBody)
+	ns.RegisterExtension(addDateSymbol, nil, addDateBody)
+	ns.RegisterExtension(timeDiffSymbol, nil, timeDiffBody)
+	ns.RegisterExtension(timeTruncateSymbol, nil, timeTruncateBody)
+	ns.RegisterExtension(timeRoundSymbol, nil, timeRoundBody)
+}
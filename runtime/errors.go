@@ -17,9 +17,12 @@ var (
 	ErrorTooManyArguments                                           int
 	ErrorInvalidOperation                                           int
 	ErrorValueOutOfRange                                            int
+	ErrorInternal                                                   int
+	ErrorInvalidValueType                                           int
 )
 
-var errorMessages map[int]string = make(map[int]string)
+// errorMessages ロケールごとの実行時エラーメッセージのカタログ
+var errorMessages map[string]map[int]string = map[string]map[int]string{parser.LocaleEnglish: make(map[int]string)}
 
 func init() {
 	ErrorTheNumberOfArgumentsDoesNotMatch = RegisterEvalError("The number of arguments does not match(%v given, %v need)")
@@ -28,25 +31,43 @@ func init() {
 	ErrorTheFirstElementOfTheListToBeEvaluatedMustBeACallableObject = RegisterEvalError("The first element of the list to be evaluated must be a callable object: %v ")
 	ErrorFunctionCannotBePassedAsFunctionArgument = RegisterEvalError("Function cannot be passed as function argument")
 	ErrorInsufficientNumberOfArguments = RegisterEvalError("Insufficient number of arguments(%v given, %v need)")
+	ErrorTooManyArguments = RegisterEvalError("Too many arguments(%v given, %v need)")
 	ErrorInvalidOperation = RegisterEvalError("Invalid Operation")
 	ErrorValueOutOfRange = RegisterEvalError("Value out of range %v(%v to %v)")
+	ErrorInternal = RegisterEvalError("Internal error: %v")
+	ErrorInvalidValueType = RegisterEvalError("Invalid value type %v")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheNumberOfArgumentsDoesNotMatch, "引数の数が一致しません(%v個指定、%v個必要)")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorUndefinedSymbol, "未定義のシンボル %v です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorAnEmptyListIsNotAllowed, "空のリストは使用できません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheFirstElementOfTheListToBeEvaluatedMustBeACallableObject, "評価するリストの最初の要素は呼び出し可能なオブジェクトでなければなりません: %v ")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorFunctionCannotBePassedAsFunctionArgument, "関数を関数の引数として渡すことはできません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInsufficientNumberOfArguments, "引数が不足しています(%v個指定、%v個必要)")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTooManyArguments, "引数が多すぎます(%v個指定、%v個必要)")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidOperation, "不正な操作です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorValueOutOfRange, "値 %v が範囲外です(%v から %v)")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInternal, "内部エラー: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidValueType, "不正な値の型 %v です")
 }
 
 // EvalError 実行時エラーの構造体
 type EvalError struct {
 	ErrorLocation parser.Position
+	ID            int
+	Args          []interface{}
 	Message       string
 }
 
 // NewEvalError 式の評価の際に発生したエラーを表すオブジェクトを生成する。
 func NewEvalError(loc parser.Position, id int, args ...interface{}) *EvalError {
-	msg, ok := errorMessages[id]
-	if !ok {
+	if _, ok := errorMessages[parser.LocaleEnglish][id]; !ok {
 		panic("Undefined error id")
 	}
 	e := new(EvalError)
 	e.ErrorLocation = loc
-	e.Message = fmt.Sprintf(msg, args...)
+	e.ID = id
+	e.Args = args
+	e.Message = fmt.Sprintf(lookupEvalErrorMessage(parser.Locale(), id), args...)
 	return e
 }
 
@@ -55,9 +76,51 @@ func (err *EvalError) Error() string {
 	return h + err.Message
 }
 
-// RegisterEvalError 実行時エラーのエラーメッセージを登録し、エラーメッセージのIDを返す。
+// Localize ロケールlocaleのエラーメッセージを返す。
+func (err *EvalError) Localize(locale string) string {
+	h := fmt.Sprintf("%s:%d:%d ", err.ErrorLocation.Filename, err.ErrorLocation.Line, err.ErrorLocation.Column)
+	return h + fmt.Sprintf(lookupEvalErrorMessage(locale, err.ID), err.Args...)
+}
+
+// withPosition errが位置情報を持たないEvalErrorの場合、その位置をlocに設定する。
+func withPosition(err error, loc parser.Position) error {
+	if e, ok := err.(*EvalError); ok && e.ErrorLocation == (parser.Position{}) {
+		e.ErrorLocation = loc
+	}
+	return err
+}
+
+// RegisterEvalError 実行時エラーのエラーメッセージ（英語）を登録し、エラーメッセージのIDを返す。
 func RegisterEvalError(msg string) int {
-	n := len(errorMessages)
-	errorMessages[n] = msg
+	en := errorMessages[parser.LocaleEnglish]
+	n := len(en)
+	en[n] = msg
 	return n
 }
+
+// RegisterEvalErrorMessage ロケールlocaleのカタログにエラーID idのエラーメッセージを登録する。
+// カタログが存在しないロケールの場合は新たにカタログを作る。
+func RegisterEvalErrorMessage(locale string, id int, msg string) {
+	if _, ok := errorMessages[parser.LocaleEnglish][id]; !ok {
+		panic("Undefined error id")
+	}
+	c, ok := errorMessages[locale]
+	if !ok {
+		c = make(map[int]string)
+		errorMessages[locale] = c
+	}
+	c[id] = msg
+}
+
+// SetLocale 構文解析と実行時のエラーメッセージの生成に使用するロケールを設定する。
+func SetLocale(locale string) {
+	parser.SetLocale(locale)
+}
+
+// lookupEvalErrorMessage ロケールlocaleのカタログからエラーメッセージを探す。見つからない場合は英語のメッセージを返す。
+func lookupEvalErrorMessage(locale string, id int) string {
+	if m, ok := errorMessages[locale][id]; ok {
+		return m
+	}
+	return errorMessages[parser.LocaleEnglish][id]
+}
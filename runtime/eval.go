@@ -3,6 +3,7 @@ package runtime
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/healthy-tiger/scalc/parser"
 )
@@ -13,11 +14,13 @@ type Function struct {
 	body        *parser.List                                                                // ユーザー定義関数の本体
 	nativeparam interface{}                                                                 // ネイティブ関数の内部パラメータ
 	native      func(obj interface{}, lst *parser.List, ns *Namespace) (interface{}, error) // ネイティブ関数の本体
+	name        string                                                                      // ネイティブ関数を登録したシンボル名
+	closure     *Namespace                                                                  // lambdaで定義した関数の場合、定義した時点の名前空間
 }
 
 func isValidType(v interface{}) bool {
 	switch v.(type) {
-	case int64, float64, string, *Function:
+	case nil, int64, float64, string, bool, time.Duration, []interface{}, *Function:
 		return true
 	default:
 		return false
@@ -30,13 +33,13 @@ func (f *Function) Eval(lst *parser.List, ns *Namespace) (interface{}, error) {
 		return f.EvalAsFunction(lst, ns)
 	} else if f.native != nil {
 		result, err := f.EvalAsNative(lst, ns)
-		// ネイティブ関数が正常に値を返しても、無効な型の場合は処理系を即座に止める。
+		// ネイティブ関数が正常に値を返しても、無効な型の場合はエラーにする。
 		if err == nil && !isValidType(result) {
-			panic(fmt.Sprintf("invalid return type %v", reflect.TypeOf(result)))
+			return nil, NewEvalError(lst.Position(), ErrorInvalidValueType, reflect.TypeOf(result))
 		}
 		return result, err
 	}
-	panic("Nil function.")
+	return nil, NewEvalError(lst.Position(), ErrorInternal, "nil function")
 }
 
 // EvalAsFunction 関数fをユーザー定義関数として、lstの第2要素以降を引数に、グローバルの名前空間globalsで評価し、その結果を返す。
@@ -44,17 +47,59 @@ func (f *Function) EvalAsFunction(lst *parser.List, ns *Namespace) (interface{},
 	if len(f.params) != lst.Len()-1 {
 		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, len(f.params), lst.Len()-1)
 	}
-	// 呼び出し先（の関数を実行する際）の名前空間を定義。最上位の名前空間以外は呼び出し元と名前空間を共有しない。
-	lns := NewNamespace(ns.Root())
-	// 引数を呼び出し元の名前空間で評価して、その結果を呼び出し先の名前空間にセット
+	// 引数を呼び出し元の名前空間で評価する。
+	args := make([]interface{}, lst.Len()-1)
 	for i := 1; i < lst.Len(); i++ {
 		a, err := EvalElement(lst.ElementAt(i), ns)
 		if err != nil {
 			return nil, err
 		}
-		lns.Set(f.params[i-1], a)
+		args[i-1] = a
+	}
+	return f.callFunction(args, lst.Position(), ns)
+}
+
+// callFunction ユーザー定義関数fを評価済みの引数argsで呼び出す。
+func (f *Function) callFunction(args []interface{}, pos parser.Position, ns *Namespace) (interface{}, error) {
+	if len(f.params) != len(args) {
+		return nil, NewEvalError(pos, ErrorTheNumberOfArgumentsDoesNotMatch, len(f.params), len(args))
+	}
+	// 呼び出し先（の関数を実行する際）の名前空間を定義。最上位の名前空間以外は呼び出し元と名前空間を共有しない。
+	// lambdaで定義した関数は、定義した時点の名前空間を親にする。
+	parent := ns.Root()
+	if f.closure != nil {
+		parent = f.closure
+	}
+	lns := NewNamespace(parent)
+	for i, a := range args {
+		if err := lns.Set(f.params[i], a); err != nil {
+			return nil, withPosition(err, pos)
+		}
+	}
+	return returnFromFunc(evalList(f.body, lns))
+}
+
+// Call 評価済みの値argsを引数に関数fを名前空間nsで呼び出し、その結果を返す。posはエラーの位置として使う。
+// ネイティブ関数は評価前の引数を受け取るため、値を一時的なシンボルに束縛した呼び出し式を組み立てて評価する。
+func (f *Function) Call(args []interface{}, pos parser.Position, ns *Namespace) (interface{}, error) {
+	if f.body != nil && f.params != nil {
+		return f.callFunction(args, pos, ns)
+	}
+	cns := NewNamespace(ns)
+	elms := make([]parser.SyntaxElement, len(args)+1)
+	for i := 0; i <= len(args); i++ {
+		var v interface{} = f
+		if i > 0 {
+			v = args[i-1]
+		}
+		// 空白を含むシンボル名はソースコードに書けないので、ユーザーのシンボルと衝突しない。
+		sid := cns.GetSymbolID(fmt.Sprintf(" arg%d", i))
+		if err := cns.Set(sid, v); err != nil {
+			return nil, withPosition(err, pos)
+		}
+		elms[i] = parser.NewSymbol(sid, pos)
 	}
-	return EvalList(f.body, lns)
+	return f.Eval(parser.NewList(pos, elms...), cns)
 }
 
 // EvalAsNative 関数fをネイティブ関数として、lstの第2要素以降を引数に、グローバルの名前空間globalsで評価し、その結果を返す。
@@ -88,6 +133,16 @@ func EvalAsFloat(elm parser.SyntaxElement, ns *Namespace) (float64, error) {
 	return -1, NewEvalError(elm.Position(), ErrorOperantsMustBeOfFloatType, r)
 }
 
+// EvalAsBool 名前空間nsでelmを評価し、その結果を真偽値として返す。真偽値でない結果の場合はエラーを返す。
+// 互換モードの場合はint64も受け付け、0以外を真とする。
+func EvalAsBool(elm parser.SyntaxElement, ns *Namespace) (bool, error) {
+	r, err := EvalElement(elm, ns)
+	if err != nil {
+		return false, err
+	}
+	return isTrue(r, elm.Position(), ns)
+}
+
 // EvalAsString 名前空間nsでelmを評価し、その結果をstringとして返す。stringでない結果の場合はエラーを返す。
 func EvalAsString(elm parser.SyntaxElement, ns *Namespace) (string, error) {
 	r, err := EvalElement(elm, ns)
@@ -104,16 +159,12 @@ func EvalAsString(elm parser.SyntaxElement, ns *Namespace) (string, error) {
 // EvalElement 構文要素を指定された名前空間で評価する。
 func EvalElement(st parser.SyntaxElement, ns *Namespace) (interface{}, error) {
 	if st.IsList() {
-		return EvalList(st.(*parser.List), ns)
+		return evalList(st.(*parser.List), ns)
 	}
 	if sid, ok := st.SymbolValue(); ok {
 		sv, ok := ns.Get(sid)
 		if !ok {
-			sn, err := ns.GetSymbolName(sid)
-			if err != nil {
-				panic(err)
-			}
-			return nil, NewEvalError(st.Position(), ErrorUndefinedSymbol, sn)
+			return nil, NewEvalError(st.Position(), ErrorUndefinedSymbol, symbolNameOrID(ns, sid))
 		}
 		return sv, nil
 	} else if ss, ok := st.StringValue(); ok {
@@ -122,13 +173,45 @@ func EvalElement(st parser.SyntaxElement, ns *Namespace) (interface{}, error) {
 		return si, nil
 	} else if sf, ok := st.FloatValue(); ok {
 		return sf, nil
+	} else if sd, ok := st.DurationValue(); ok {
+		return sd, nil
 	} else {
-		panic(fmt.Sprintf("Illegal syntax tree element %v", reflect.TypeOf(st)))
+		return nil, NewEvalError(st.Position(), ErrorInternal, fmt.Sprintf("Illegal syntax tree element %v", reflect.TypeOf(st)))
 	}
 }
 
+// evalSequence lstのstart番目以降の要素を名前空間nsで順に評価し、最後の要素の評価結果を返す。
+func evalSequence(lst *parser.List, start int, ns *Namespace) (interface{}, error) {
+	var result interface{}
+	for i := start; i < lst.Len(); i++ {
+		var err error
+		result, err = EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 // EvalList リストlstを名前空間のもとで評価する。
+// 評価中に発生したパニックは回復し、内部エラーとして返す。
+// ループまたはユーザー定義関数の外で使われたbreak、continue、returnは*EvalErrorとして返す。
 func EvalList(lst *parser.List, ns *Namespace) (interface{}, error) {
+	result, err := evalList(lst, ns)
+	if cf, ok := err.(*controlFlow); ok {
+		return nil, cf.evalError()
+	}
+	return result, err
+}
+
+// evalList リストlstを名前空間のもとで評価する。break、continue、returnはcontrolFlowのまま呼び出し元に伝播する。
+func evalList(lst *parser.List, ns *Namespace) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = NewEvalError(lst.Position(), ErrorInternal, r)
+		}
+	}()
 	// 空のリストは評価できないのでエラー(Excentionがリストを評価する場合はExtentionsによる）
 	if lst.Len() == 0 {
 		return nil, NewEvalError(lst.Position(), ErrorAnEmptyListIsNotAllowed)
@@ -148,9 +231,21 @@ func EvalList(lst *parser.List, ns *Namespace) (interface{}, error) {
 // MakeDefaultNamespace 予約済みのシンボルをシンボルテーブに登録し、その値を登録済みの名前空間を作る。
 func MakeDefaultNamespace(ns *Namespace) {
 	RegisterBoolType(ns)
+	RegisterNilType(ns)
+	RegisterListType(ns)
 	RegisterOperators(ns)
 	RegisterMath(ns)
 	RegisterStmt(ns)
+	RegisterScope(ns)
+	RegisterConditional(ns)
+	RegisterLoop(ns)
+	RegisterFunctional(ns)
 	RegisterTimeFunc(ns)
+	RegisterClock(ns)
+	RegisterDuration(ns)
+	RegisterCalendar(ns)
 	RegisterStrings(ns)
+	RegisterFormat(ns)
+	RegisterRegexp(ns)
+	RegisterUnicode(ns)
 }
@@ -0,0 +1,57 @@
+package runtime_test
+
+import (
+	"testing"
+
+	"github.com/healthy-tiger/scalc/parser"
+	"github.com/healthy-tiger/scalc/runtime"
+)
+
+func evalExtensionTest(t *testing.T, name string, body func(interface{}, *parser.List, *runtime.Namespace) (interface{}, error)) error {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	ns.RegisterExtension("ext", nil, body)
+	lists, err := parser.ParseString(name, st, `(+ 1 (ext))`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	_, err = runtime.EvalList(lists[0], ns)
+	return err
+}
+
+func TestInvalidReturnType(t *testing.T) {
+	err := evalExtensionTest(t, "TestInvalidReturnType", func(_ interface{}, _ *parser.List, _ *runtime.Namespace) (interface{}, error) {
+		return int32(1), nil
+	})
+	ee, ok := err.(*runtime.EvalError)
+	if !ok {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ee.ID != runtime.ErrorInvalidValueType {
+		t.Errorf("Unexpected error id: %v", ee)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	err := evalExtensionTest(t, "TestRecoverPanic", func(_ interface{}, _ *parser.List, _ *runtime.Namespace) (interface{}, error) {
+		panic("buggy extension")
+	})
+	ee, ok := err.(*runtime.EvalError)
+	if !ok {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ee.ID != runtime.ErrorInternal || ee.ErrorLocation.Column != 6 {
+		t.Errorf("Unexpected error: %v", ee)
+	}
+}
+
+func TestSetInvalidValue(t *testing.T) {
+	ns := runtime.NewRootNamespace(parser.NewSymbolTable())
+	if err := ns.Set(ns.GetSymbolID("a"), int32(1)); err == nil {
+		t.Error("No error")
+	}
+	if err := ns.Set(ns.GetSymbolID("a"), int64(1)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,282 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	formatSymbol    = "format"
+	printfSymbol    = "printf"
+	fmtNumberSymbol = "fmt-number"
+)
+
+// 書式化に関するエラーコード
+var (
+	ErrorInvalidFormatVerb        int
+	ErrorFormatVerbDoesNotMatch   int
+	ErrorTooFewArgumentsToFormat  int
+	ErrorTooManyArgumentsToFormat int
+)
+
+func init() {
+	ErrorInvalidFormatVerb = RegisterEvalError("Invalid format verb %v")
+	ErrorFormatVerbDoesNotMatch = RegisterEvalError("The format verb %v does not match the argument %v")
+	ErrorTooFewArgumentsToFormat = RegisterEvalError("Too few arguments for the format (%v given, %v need)")
+	ErrorTooManyArgumentsToFormat = RegisterEvalError("Too many arguments for the format (%v given, %v need)")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidFormatVerb, "書式指定子 %v が不正です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorFormatVerbDoesNotMatch, "書式指定子 %v が引数 %v に一致しません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTooFewArgumentsToFormat, "書式に対して引数が不足しています(%v個指定、%v個必要)")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTooManyArgumentsToFormat, "書式に対して引数が多すぎます(%v個指定、%v個必要)")
+}
+
+// formatVerbs 値の型ごとに使用できる書式指定子。vはどの型にも使用でき、strと同じ文字列表現になる。
+var formatVerbs = map[string]string{
+	"int64":         "bcdoOqxXU",
+	"float64":       "eEfFgGxX",
+	"string":        "sqxX",
+	"bool":          "t",
+	"time.Duration": "dsq", // %dはナノ秒の整数、%sと%qは1h30m0sの形式
+}
+
+// formatDirective 書式に含まれる一つの書式指定（%5.2fなど）
+type formatDirective struct {
+	text string
+	verb rune
+}
+
+// parseFormat 書式formatに含まれる書式指定を順に返す。%%は含まない。
+func parseFormat(format string, pos parser.Position) ([]formatDirective, error) {
+	result := make([]formatDirective, 0)
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			i++
+			continue
+		}
+		start := i
+		i++
+		if i < len(format) && format[i] == '%' {
+			i++
+			continue
+		}
+		// フラグ、幅、精度を読み飛ばす。引数の位置指定と*による幅の指定は扱わない。
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+			i++
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				i++
+			}
+		}
+		if i >= len(format) {
+			return nil, NewEvalError(pos, ErrorInvalidFormatVerb, format[start:])
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size
+		if verb != 'v' && !strings.ContainsRune("bcdoOqxXUeEfFgGst", verb) {
+			return nil, NewEvalError(pos, ErrorInvalidFormatVerb, format[start:i])
+		}
+		result = append(result, formatDirective{format[start:i], verb})
+	}
+	return result, nil
+}
+
+// formatArgs 書式formatの書式指定子を引数argsの型と照合し、fmt.Sprintfに渡す引数を返す。
+// %vで書式化する値はstrと同じ文字列表現に変換する。intBoolがtrueの場合は%tでint64を真偽値として書式化する。
+func formatArgs(format string, args []interface{}, intBool bool, pos parser.Position) ([]interface{}, error) {
+	directives, err := parseFormat(format, pos)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < len(directives) {
+		return nil, NewEvalError(pos, ErrorTooFewArgumentsToFormat, len(args), len(directives))
+	} else if len(args) > len(directives) {
+		return nil, NewEvalError(pos, ErrorTooManyArgumentsToFormat, len(args), len(directives))
+	}
+	result := make([]interface{}, len(args))
+	for i, d := range directives {
+		a := args[i]
+		if d.verb == 'v' {
+			s, ok := displayString(a)
+			if !ok {
+				return nil, NewEvalError(pos, ErrorFormatVerbDoesNotMatch, d.text, a)
+			}
+			result[i] = s
+		} else if n, ok := a.(int64); ok && intBool && d.verb == 't' {
+			// 互換モードでは真偽値をint64の1と0で表す。
+			result[i] = n != 0
+		} else if strings.ContainsRune(formatVerbs[fmt.Sprintf("%T", a)], d.verb) {
+			result[i] = a
+		} else {
+			return nil, NewEvalError(pos, ErrorFormatVerbDoesNotMatch, d.text, a)
+		}
+	}
+	return result, nil
+}
+
+// evalFormat (関数名 書式 引数 ...)の形式のlstを評価し、書式化した文字列を返す。
+func evalFormat(lst *parser.List, ns *Namespace) (string, error) {
+	if lst.Len() < 2 {
+		return "", NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
+	}
+	format, err := EvalAsString(lst.ElementAt(1), ns)
+	if err != nil {
+		return "", err
+	}
+	args := make([]interface{}, lst.Len()-2)
+	for i := 2; i < lst.Len(); i++ {
+		v, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return "", err
+		}
+		args[i-2] = v
+	}
+	fargs, err := formatArgs(format, args, ns.Config().IntBool, lst.ElementAt(1).Position())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(format, fargs...), nil
+}
+
+// formatBody (format 書式 引数 ...)
+// Goのfmt.Sprintfと同じ書式指定子で引数を書式化した文字列を返す。
+// 埋め込み式付き文字列リテラルを展開したstr-interpolateもこの関数で評価する。
+func formatBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalFormat(lst, ns)
+}
+
+// printfBody (printf 書式 引数 ...)
+// formatと同じく書式化した文字列を改行せずに出力し、出力したバイト数を返す。
+func printfBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	s, err := evalFormat(lst, ns)
+	if err != nil {
+		return nil, err
+	}
+	np, err := fmt.Print(s)
+	return int64(np), err
+}
+
+var (
+	numberSeparatorsLock sync.RWMutex
+	// numberSeparators ロケールごとの数値の桁区切り文字と小数点
+	numberSeparators = map[string][2]string{
+		parser.LocaleEnglish:  {",", "."},
+		parser.LocaleJapanese: {",", "."},
+		"de":                  {".", ","},
+		"es":                  {".", ","},
+		"it":                  {".", ","},
+		"pt":                  {".", ","},
+		"fr":                  {"\u202f", ","},
+		"ru":                  {"\u00a0", ","},
+	}
+)
+
+// RegisterNumberSeparators fmt-numberで使うロケールlocaleの桁区切り文字groupと小数点decimalを登録する。
+func RegisterNumberSeparators(locale string, group string, decimal string) {
+	numberSeparatorsLock.Lock()
+	defer numberSeparatorsLock.Unlock()
+	numberSeparators[locale] = [2]string{group, decimal}
+}
+
+// lookupNumberSeparators ロケールlocaleの桁区切り文字と小数点を返す。登録されていないロケールの場合は英語のものを返す。
+func lookupNumberSeparators(locale string) (string, string) {
+	numberSeparatorsLock.RLock()
+	defer numberSeparatorsLock.RUnlock()
+	sep, ok := numberSeparators[locale]
+	if !ok {
+		sep = numberSeparators[parser.LocaleEnglish]
+	}
+	return sep[0], sep[1]
+}
+
+// groupNumber strconvで書式化した数値sの整数部を3桁ごとにgroupで区切り、小数点をdecimalに置き換える。
+func groupNumber(s string, group string, decimal string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intpart, fracpart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intpart, fracpart = s[:i], s[i+1:]
+	}
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, c := range intpart {
+		if i > 0 && (len(intpart)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(c)
+	}
+	if fracpart != "" {
+		b.WriteString(decimal)
+		b.WriteString(fracpart)
+	}
+	return b.String()
+}
+
+// fmtNumberBody (fmt-number 数値 [小数点以下の桁数 [ロケール]])
+// 数値を桁区切りして書式化した文字列を返す。ロケールを省略した場合は現在のロケールの区切り文字を使う。
+// 桁数を省略した場合、整数は小数部なし、浮動小数点数は値を表すのに必要な桁数になる。
+func fmtNumberBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 || lst.Len() > 4 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	v, err := EvalElement(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	prec := int64(-1)
+	if lst.Len() >= 3 {
+		prec, err = EvalAsInt(lst.ElementAt(2), ns)
+		if err != nil {
+			return nil, err
+		}
+		if prec < 0 || prec > 20 {
+			return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorValueOutOfRange, prec, 0, 20)
+		}
+	}
+	locale := parser.Locale()
+	if lst.Len() == 4 {
+		locale, err = EvalAsString(lst.ElementAt(3), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var s string
+	switch n := v.(type) {
+	case int64:
+		// float64に変換すると2^53を超える値の精度が落ちるので、小数部は0を並べる。
+		s = strconv.FormatInt(n, 10)
+		if prec > 0 {
+			s += "." + strings.Repeat("0", int(prec))
+		}
+	case float64:
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return strconv.FormatFloat(n, 'f', -1, 64), nil
+		}
+		s = strconv.FormatFloat(n, 'f', int(prec), 64)
+	default:
+		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorOperantsMustBeNumeric, v)
+	}
+	group, decimal := lookupNumberSeparators(locale)
+	return groupNumber(s, group, decimal), nil
+}
+
+// RegisterFormat 書式化に関する拡張関数を登録する。
+func RegisterFormat(ns *Namespace) {
+	ns.RegisterExtension(formatSymbol, nil, formatBody)
+	ns.RegisterExtension(printfSymbol, nil, printfBody)
+	ns.RegisterExtension(fmtNumberSymbol, nil, fmtNumberBody)
+	ns.RegisterExtension(parser.InterpolationSymbol, nil, formatBody)
+}
@@ -0,0 +1,253 @@
+package runtime
+
+import (
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	applySymbol   = "apply"
+	mapSymbol     = "map"
+	filterSymbol  = "filter"
+	reduceSymbol  = "reduce"
+	composeSymbol = "compose"
+	lambdaSymbol  = "lambda"
+)
+
+// 高階関数に関するエラーコード
+var (
+	ErrorOperantsMustBeOfFunctionType int
+	ErrorReduceOfEmptyCollection      int
+)
+
+func init() {
+	ErrorOperantsMustBeOfFunctionType = RegisterEvalError("Operants must be of function type: %v")
+	ErrorReduceOfEmptyCollection = RegisterEvalError("Reduce of empty collection with no initial value")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorOperantsMustBeOfFunctionType, "オペラントは関数でなければなりません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorReduceOfEmptyCollection, "初期値なしで空のコレクションを畳み込むことはできません")
+}
+
+// evalAsFunc 名前空間nsでelmを評価し、その結果を関数として返す。関数でない結果の場合はエラーを返す。
+func evalAsFunc(elm parser.SyntaxElement, ns *Namespace) (*Function, error) {
+	v, err := EvalElement(elm, ns)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := v.(*Function)
+	if !ok {
+		return nil, NewEvalError(elm.Position(), ErrorOperantsMustBeOfFunctionType, v)
+	}
+	return f, nil
+}
+
+// evalAsCollection 名前空間nsでelmを評価し、その結果のコレクションの要素を返す。
+func evalAsCollection(elm parser.SyntaxElement, ns *Namespace) ([]interface{}, error) {
+	v, err := EvalElement(elm, ns)
+	if err != nil {
+		return nil, err
+	}
+	return collect(v, elm.Position())
+}
+
+// applyBody (apply 関数 引数 ... コレクション)
+// 引数とコレクションの要素を順に並べたものを引数にして関数を呼び出す。
+func applyBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	f, err := evalAsFunc(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, lst.Len())
+	for i := 2; i < lst.Len()-1; i++ {
+		v, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	rest, err := evalAsCollection(lst.ElementAt(lst.Len()-1), ns)
+	if err != nil {
+		return nil, err
+	}
+	return f.Call(append(args, rest...), lst.Position(), ns)
+}
+
+// mapBody (map 関数 コレクション ...)
+// 各コレクションの同じ位置の要素を引数に関数を呼び出し、その結果のリストを返す。最も短いコレクションの長さで打ち切る。
+func mapBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	f, err := evalAsFunc(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	colls := make([][]interface{}, lst.Len()-2)
+	n := -1
+	for i := 2; i < lst.Len(); i++ {
+		c, err := evalAsCollection(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		colls[i-2] = c
+		if n < 0 || len(c) < n {
+			n = len(c)
+		}
+	}
+	result := make([]interface{}, n)
+	for j := 0; j < n; j++ {
+		args := make([]interface{}, len(colls))
+		for i, c := range colls {
+			args[i] = c[j]
+		}
+		v, err := f.Call(args, lst.Position(), ns)
+		if err != nil {
+			return nil, err
+		}
+		result[j] = v
+	}
+	return result, nil
+}
+
+// filterBody (filter 述語 コレクション)
+// 述語が真を返した要素だけのリストを返す。
+func filterBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	f, err := evalAsFunc(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	c, err := evalAsCollection(lst.ElementAt(2), ns)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(c))
+	for _, e := range c {
+		v, err := f.Call([]interface{}{e}, lst.Position(), ns)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := isTrue(v, lst.ElementAt(1).Position(), ns)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// reduceBody (reduce 関数 [初期値] コレクション)
+// 累積値と要素を引数に関数を順に呼び出し、最後の結果を返す。初期値を省略した場合は最初の要素を初期値とする。
+func reduceBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	} else if lst.Len() > 4 {
+		return nil, NewEvalError(lst.Position(), ErrorTooManyArguments, lst.Len()-1, 3)
+	}
+	f, err := evalAsFunc(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	var acc interface{}
+	if lst.Len() == 4 {
+		acc, err = EvalElement(lst.ElementAt(2), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c, err := evalAsCollection(lst.ElementAt(lst.Len()-1), ns)
+	if err != nil {
+		return nil, err
+	}
+	if lst.Len() == 3 {
+		if len(c) == 0 {
+			return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorReduceOfEmptyCollection)
+		}
+		acc, c = c[0], c[1:]
+	}
+	for _, e := range c {
+		acc, err = f.Call([]interface{}{acc, e}, lst.Position(), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// composedBody composeで合成した関数の本体。引数を最後の関数に渡し、その結果を前の関数に順に渡していく。
+func composedBody(fns interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	funcs := fns.([]*Function)
+	args := make([]interface{}, lst.Len()-1)
+	for i := 1; i < lst.Len(); i++ {
+		v, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		args[i-1] = v
+	}
+	var result interface{}
+	for i := len(funcs) - 1; i >= 0; i-- {
+		var err error
+		result, err = funcs[i].Call(args, lst.Position(), ns)
+		if err != nil {
+			return nil, err
+		}
+		args = []interface{}{result}
+	}
+	return result, nil
+}
+
+// composeBody (compose 関数 ...)
+// 関数を右から順に適用する関数を返す。((compose f g) x)は(f (g x))と同じになる。
+func composeBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
+	}
+	funcs := make([]*Function, lst.Len()-1)
+	for i := 1; i < lst.Len(); i++ {
+		f, err := evalAsFunc(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		funcs[i-1] = f
+	}
+	return &Function{nil, nil, funcs, composedBody, "", nil}, nil
+}
+
+// lambdaBody (lambda (引数 ...) 式 ...)
+// funcと同じく無名の関数を返す。本体に複数の式やリスト以外の式（(lambda (x) x)など）を書いた場合はbeginで囲んだものとして扱う。
+// funcと異なり、定義した時点の名前空間を保持するので、本体から外側の関数の引数やletの変数を参照できる。
+func lambdaBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	def := lst
+	if lst.Len() > 3 || (lst.Len() == 3 && !lst.ElementAt(2).IsList()) {
+		body := make([]parser.SyntaxElement, 0, lst.Len()-1)
+		body = append(body, parser.NewSymbol(ns.GetSymbolID(beginSymbol), lst.ElementAt(2).Position()))
+		for i := 2; i < lst.Len(); i++ {
+			body = append(body, lst.ElementAt(i))
+		}
+		def = parser.NewList(lst.Position(), lst.ElementAt(0), lst.ElementAt(1), parser.NewList(lst.ElementAt(2).Position(), body...))
+	}
+	v, err := funcBody(nil, def, ns)
+	if err != nil {
+		return nil, err
+	}
+	f := v.(*Function)
+	f.closure = ns
+	return f, nil
+}
+
+// RegisterFunctional 高階関数に関する拡張関数を登録する。
+func RegisterFunctional(ns *Namespace) {
+	ns.RegisterExtension(applySymbol, nil, applyBody)
+	ns.RegisterExtension(mapSymbol, nil, mapBody)
+	ns.RegisterExtension(filterSymbol, nil, filterBody)
+	ns.RegisterExtension(reduceSymbol, nil, reduceBody)
+	ns.RegisterExtension(composeSymbol, nil, composeBody)
+	ns.RegisterExtension(lambdaSymbol, nil, lambdaBody)
+}
@@ -0,0 +1,181 @@
+package runtime
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// JapaneseHolidayCalendar 日本の国民の祝日と休日の暦の名前
+const JapaneseHolidayCalendar = "jp"
+
+// monthDay 年を含まない日付
+type monthDay struct {
+	month time.Month
+	day   int
+}
+
+// japaneseHolidays 祝日法の規則から計算した日本の祝日。春分の日と秋分の日の計算式が有効な1949年から2099年までを扱う。
+// 年ごとに計算した結果を保持し、複数のゴルーチンから同時に使用できる。
+type japaneseHolidays struct {
+	mutex sync.Mutex
+	years map[int]map[monthDay]string
+}
+
+// japaneseSpecialHolidays 特別法による一度限りの休日
+var japaneseSpecialHolidays = map[int]map[monthDay]string{
+	1959: {{4, 10}: "皇太子明仁親王の結婚の儀"},
+	1989: {{2, 24}: "昭和天皇の大喪の礼"},
+	1990: {{11, 12}: "即位礼正殿の儀"},
+	1993: {{6, 9}: "皇太子徳仁親王の結婚の儀"},
+	2019: {{5, 1}: "天皇の即位の日", {10, 22}: "即位礼正殿の儀"},
+}
+
+// Holiday 日付が日本の祝日または休日の場合は名前とtrueを返す。
+func (h *japaneseHolidays) Holiday(year int, month time.Month, day int) (string, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	hs, ok := h.years[year]
+	if !ok {
+		hs = computeJapaneseHolidays(year)
+		h.years[year] = hs
+	}
+	name, ok := hs[monthDay{month, day}]
+	return name, ok
+}
+
+// nthMonday year年month月の第n月曜日の日を返す。
+func nthMonday(year int, month time.Month, n int) int {
+	wd := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+	return 1 + (8-int(wd))%7 + (n-1)*7
+}
+
+// equinoxDay 春分日（base=20.8431）または秋分日（base=23.2488）の日を返す。
+func equinoxDay(year int, base float64) int {
+	if year < 1980 {
+		// 1979年以前は別の近似式を使う。
+		return int(base - 0.0074 + 0.0100*(base-20.8431)/(23.2488-20.8431) + 0.242194*float64(year-1980) - math.Floor(float64(year-1983)/4))
+	}
+	return int(base + 0.242194*float64(year-1980) - math.Floor(float64(year-1980)/4))
+}
+
+// computeJapaneseHolidays year年の日本の祝日と、振替休日、国民の休日を計算する。
+func computeJapaneseHolidays(year int) map[monthDay]string {
+	hs := make(map[monthDay]string)
+	if year < 1949 || year > 2099 {
+		return hs
+	}
+	add := func(m time.Month, d int, name string) {
+		hs[monthDay{m, d}] = name
+	}
+
+	add(1, 1, "元日")
+	if year >= 2000 {
+		add(1, nthMonday(year, 1, 2), "成人の日")
+	} else {
+		add(1, 15, "成人の日")
+	}
+	if year >= 1967 {
+		add(2, 11, "建国記念の日")
+	}
+	if year >= 2020 {
+		add(2, 23, "天皇誕生日")
+	}
+	add(3, equinoxDay(year, 20.8431), "春分の日")
+	switch {
+	case year >= 2007:
+		add(4, 29, "昭和の日")
+	case year >= 1989:
+		add(4, 29, "みどりの日")
+	default:
+		add(4, 29, "天皇誕生日")
+	}
+	add(5, 3, "憲法記念日")
+	if year >= 2007 {
+		add(5, 4, "みどりの日")
+	}
+	add(5, 5, "こどもの日")
+	switch {
+	case year == 2020:
+		add(7, 23, "海の日")
+	case year == 2021:
+		add(7, 22, "海の日")
+	case year >= 2003:
+		add(7, nthMonday(year, 7, 3), "海の日")
+	case year >= 1996:
+		add(7, 20, "海の日")
+	}
+	switch {
+	case year == 2020:
+		add(8, 10, "山の日")
+	case year == 2021:
+		add(8, 8, "山の日")
+	case year >= 2016:
+		add(8, 11, "山の日")
+	}
+	switch {
+	case year >= 2003:
+		add(9, nthMonday(year, 9, 3), "敬老の日")
+	case year >= 1966:
+		add(9, 15, "敬老の日")
+	}
+	add(9, equinoxDay(year, 23.2488), "秋分の日")
+	switch {
+	case year == 2020:
+		add(7, 24, "スポーツの日")
+	case year == 2021:
+		add(7, 23, "スポーツの日")
+	case year >= 2020:
+		add(10, nthMonday(year, 10, 2), "スポーツの日")
+	case year >= 2000:
+		add(10, nthMonday(year, 10, 2), "体育の日")
+	case year >= 1966:
+		add(10, 10, "体育の日")
+	}
+	add(11, 3, "文化の日")
+	add(11, 23, "勤労感謝の日")
+	if year >= 1989 && year <= 2018 {
+		add(12, 23, "天皇誕生日")
+	}
+	for md, name := range japaneseSpecialHolidays[year] {
+		add(md.month, md.day, name)
+	}
+
+	// 国民の休日（1988年以降）：前日と翌日が祝日である祝日でない日。日曜日は除く。
+	if year >= 1988 {
+		for d := time.Date(year, 1, 2, 0, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+			prev, next := d.AddDate(0, 0, -1), d.AddDate(0, 0, 1)
+			_, isHoliday := hs[monthDay{d.Month(), d.Day()}]
+			_, prevHoliday := hs[monthDay{prev.Month(), prev.Day()}]
+			_, nextHoliday := hs[monthDay{next.Month(), next.Day()}]
+			if !isHoliday && prevHoliday && nextHoliday && d.Weekday() != time.Sunday && next.Year() == year {
+				hs[monthDay{d.Month(), d.Day()}] = "国民の休日"
+			}
+		}
+	}
+
+	// 振替休日（1973年4月12日以降）：祝日が日曜日の場合、2007年以降はその後の最初の祝日でない日、それ以前は翌日を休日とする。
+	substitutes := make([]monthDay, 0)
+	for md, name := range hs {
+		d := time.Date(year, md.month, md.day, 0, 0, 0, 0, time.UTC)
+		if d.Weekday() != time.Sunday || name == "国民の休日" || d.Before(time.Date(1973, 4, 12, 0, 0, 0, 0, time.UTC)) {
+			continue
+		}
+		s := d.AddDate(0, 0, 1)
+		for year >= 2007 {
+			if _, ok := hs[monthDay{s.Month(), s.Day()}]; !ok {
+				break
+			}
+			s = s.AddDate(0, 0, 1)
+		}
+		if s.Year() == year {
+			substitutes = append(substitutes, monthDay{s.Month(), s.Day()})
+		}
+	}
+	for _, md := range substitutes {
+		if _, ok := hs[md]; !ok {
+			hs[md] = "振替休日"
+		}
+	}
+	return hs
+}
@@ -0,0 +1,114 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	listSymbol   = "list"
+	isListSymbol = "is-list"
+)
+
+// valuesEqual aとbが同じ型で等しい値ならtrueを返す。リストは要素ごとに比較する。
+func valuesEqual(a, b interface{}) bool {
+	la, oka := a.([]interface{})
+	lb, okb := b.([]interface{})
+	if oka != okb {
+		return false
+	}
+	if !oka {
+		return a == b
+	}
+	if len(la) != len(lb) {
+		return false
+	}
+	for i := range la {
+		if !valuesEqual(la[i], lb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// listString リストlを(要素 要素 ...)の形式の文字列に変換する。文字列の要素は引用符で囲む。
+func listString(l []interface{}) string {
+	var b bytes.Buffer
+	b.WriteString("(")
+	for i, e := range l {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		switch v := e.(type) {
+		case string:
+			b.WriteString(parser.QuoteString(v))
+		case bool:
+			b.WriteString(strconv.FormatBool(v))
+		case nil:
+			b.WriteString(nilSymbol)
+		case []interface{}:
+			b.WriteString(listString(v))
+		default:
+			b.WriteString(fmt.Sprint(v))
+		}
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// collect コレクションvの要素をスライスとして返す。リストの場合はそのまま返す。
+func collect(v interface{}, pos parser.Position) ([]interface{}, error) {
+	if l, ok := v.([]interface{}); ok {
+		return l, nil
+	}
+	result := make([]interface{}, 0)
+	err := iterate(v, pos, func(e interface{}) (bool, error) {
+		result = append(result, e)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// listBody 引数を評価した値を要素とするリストを返す。
+func listBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	result := make([]interface{}, lst.Len()-1)
+	for i := 1; i < lst.Len(); i++ {
+		v, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		result[i-1] = v
+	}
+	return result, nil
+}
+
+func isListBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	params := make([]interface{}, lst.Len())
+	for i := 1; i < lst.Len(); i++ {
+		p, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = p
+	}
+
+	for i := 1; i < lst.Len(); i++ {
+		_, ok := params[i].([]interface{})
+		if !ok {
+			return BoolValue(false, ns), nil
+		}
+	}
+	return BoolValue(true, ns), nil
+}
+
+// RegisterListType nsにリストを扱う拡張関数を登録する。
+func RegisterListType(ns *Namespace) {
+	ns.RegisterExtension(listSymbol, nil, listBody)
+	ns.RegisterExtension(isListSymbol, nil, isListBody)
+}
@@ -0,0 +1,265 @@
+package runtime
+
+import (
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	forSymbol      = "for"
+	foreachSymbol  = "foreach"
+	breakSymbol    = "break"
+	continueSymbol = "continue"
+	returnSymbol   = "return"
+)
+
+// ループに関するエラーコード
+var (
+	ErrorCannotBeUsedOutsideOfALoop int
+	ErrorCannotBeUsedOutsideOfAFunc int
+	ErrorALoopRequiresALoopVariable int
+	ErrorTheStepMustNotBeZero       int
+	ErrorTheValueIsNotIterable      int
+)
+
+func init() {
+	ErrorCannotBeUsedOutsideOfALoop = RegisterEvalError("%v cannot be used outside of a loop.")
+	ErrorCannotBeUsedOutsideOfAFunc = RegisterEvalError("%v cannot be used outside of a user-defined function.")
+	ErrorALoopRequiresALoopVariable = RegisterEvalError("A loop requires a list of a loop variable and its range.")
+	ErrorTheStepMustNotBeZero = RegisterEvalError("The step must not be zero.")
+	ErrorTheValueIsNotIterable = RegisterEvalError("The value is not iterable: %v")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorCannotBeUsedOutsideOfALoop, "%v はループの外では使用できません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorCannotBeUsedOutsideOfAFunc, "%v はユーザー定義関数の外では使用できません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorALoopRequiresALoopVariable, "ループにはループ変数とその範囲のリストが必要です。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheStepMustNotBeZero, "増分に0は指定できません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheValueIsNotIterable, "繰り返し処理できない値です: %v")
+}
+
+// 制御の移動の種類
+const (
+	flowBreak = iota
+	flowContinue
+	flowReturn
+)
+
+var flowSymbols = map[int]string{
+	flowBreak:    breakSymbol,
+	flowContinue: continueSymbol,
+	flowReturn:   returnSymbol,
+}
+
+// controlFlow break、continue、returnによる制御の移動を表す。
+// エラーとして呼び出し元に伝播し、break、continueは最も内側のループで、returnは最も内側のユーザー定義関数の呼び出しで取り除かれる。
+type controlFlow struct {
+	kind     int
+	value    interface{} // 値が指定されていない場合はnil
+	hasValue bool        // 値が指定された場合はtrue。(break nil)と(break)を区別する。
+	position parser.Position
+}
+
+// evalError ループまたはユーザー定義関数の外に伝播したcontrolFlowを実行時エラーに変換する。
+func (cf *controlFlow) evalError() *EvalError {
+	if cf.kind == flowReturn {
+		return NewEvalError(cf.position, ErrorCannotBeUsedOutsideOfAFunc, flowSymbols[cf.kind])
+	}
+	return NewEvalError(cf.position, ErrorCannotBeUsedOutsideOfALoop, flowSymbols[cf.kind])
+}
+
+func (cf *controlFlow) Error() string {
+	return cf.evalError().Error()
+}
+
+// returnFromFunc ユーザー定義関数の本体の評価結果を処理する。
+// returnの場合はその値を関数の評価結果にし、関数の呼び出しを越えられないbreak、continueは実行時エラーに変換する。
+func returnFromFunc(result interface{}, err error) (interface{}, error) {
+	if cf, ok := err.(*controlFlow); ok {
+		if cf.kind == flowReturn {
+			return cf.value, nil
+		}
+		return nil, cf.evalError()
+	}
+	return result, err
+}
+
+// loopState ループの結果を管理する。
+type loopState struct {
+	count      int64       // 本体を評価した回数
+	carried    interface{} // 最後に実行されたbreak、continueの値
+	hasCarried bool        // break、continueで値が指定されたことがある場合はtrue
+}
+
+// handle ループの本体の評価結果のエラーを処理する。ループを抜ける場合はtrueを返す。
+// break、continue以外のエラー（returnを含む）はそのまま返す。
+func (ls *loopState) handle(err error) (bool, error) {
+	ls.count++
+	if err == nil {
+		return false, nil
+	}
+	cf, ok := err.(*controlFlow)
+	if !ok || cf.kind == flowReturn {
+		return true, err
+	}
+	if cf.hasValue {
+		ls.carried, ls.hasCarried = cf.value, true
+	}
+	return cf.kind == flowBreak, nil
+}
+
+// result ループの結果を返す。break、continueで値が指定された場合は最後の値を、そうでなければ本体を評価した回数を返す。
+func (ls *loopState) result() interface{} {
+	if ls.hasCarried {
+		return ls.carried
+	}
+	return ls.count
+}
+
+// loopHeader (シンボル 式 ...)の形式のループ変数の定義を取り出す。
+func loopHeader(lst *parser.List, minlen int, maxlen int) (*parser.List, parser.SymbolID, error) {
+	if lst.Len() < 3 {
+		return nil, parser.InvalidSymbolID, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	h := lst.ElementAt(1)
+	if !h.IsList() || h.(*parser.List).Len() < minlen || h.(*parser.List).Len() > maxlen {
+		return nil, parser.InvalidSymbolID, NewEvalError(h.Position(), ErrorALoopRequiresALoopVariable)
+	}
+	hl := h.(*parser.List)
+	sid, ok := hl.SymbolAt(0)
+	if !ok {
+		return nil, parser.InvalidSymbolID, NewEvalError(hl.ElementAt(0).Position(), ErrorYouCannotBindAValueToAnythingOtherThanASymbol)
+	}
+	return hl, sid, nil
+}
+
+// forBody (for (変数 開始 終了 [増分]) 式 ...)
+// 変数を開始から終了まで（終了を含む）増分ずつ変化させながら式を順に評価する。
+// whileと同様に、変数と式の評価には現在の名前空間を使用する。
+func forBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	hl, sid, err := loopHeader(lst, 3, 4)
+	if err != nil {
+		return nil, err
+	}
+	start, err := EvalAsInt(hl.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	end, err := EvalAsInt(hl.ElementAt(2), ns)
+	if err != nil {
+		return nil, err
+	}
+	step := int64(1)
+	if hl.Len() == 4 {
+		step, err = EvalAsInt(hl.ElementAt(3), ns)
+		if err != nil {
+			return nil, err
+		}
+		if step == 0 {
+			return nil, NewEvalError(hl.ElementAt(3).Position(), ErrorTheStepMustNotBeZero)
+		}
+	}
+	var ls loopState
+	for i := start; (step > 0 && i <= end) || (step < 0 && i >= end); i += step {
+		if err := ns.Set(sid, i); err != nil {
+			return nil, withPosition(err, hl.ElementAt(0).Position())
+		}
+		_, err := evalSequence(lst, 2, ns)
+		if exit, err := ls.handle(err); err != nil {
+			return nil, err
+		} else if exit {
+			break
+		}
+		// 次の値がint64の範囲を超える場合は、終了に達したものとしてループを抜ける。
+		if next := i + step; (step > 0 && next < i) || (step < 0 && next > i) {
+			break
+		}
+	}
+	return ls.result(), nil
+}
+
+// iterate コレクションvの要素を順にfに渡す。fがfalseを返すかエラーを返した時点で終了する。
+// リストの場合は要素を、文字列の場合は1文字（rune）ずつの文字列を渡す。
+func iterate(v interface{}, pos parser.Position, f func(interface{}) (bool, error)) error {
+	switch c := v.(type) {
+	case []interface{}:
+		for _, e := range c {
+			if cont, err := f(e); err != nil || !cont {
+				return err
+			}
+		}
+		return nil
+	case string:
+		for _, r := range c {
+			if cont, err := f(string(r)); err != nil || !cont {
+				return err
+			}
+		}
+		return nil
+	}
+	return NewEvalError(pos, ErrorTheValueIsNotIterable, v)
+}
+
+// foreachBody (foreach (変数 コレクション) 式 ...)
+// コレクションの要素を順に現在の名前空間の変数に束縛して式を順に評価する。
+func foreachBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	hl, sid, err := loopHeader(lst, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := EvalElement(hl.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	var ls loopState
+	err = iterate(coll, hl.ElementAt(1).Position(), func(e interface{}) (bool, error) {
+		if err := ns.Set(sid, e); err != nil {
+			return false, withPosition(err, hl.ElementAt(0).Position())
+		}
+		_, err := evalSequence(lst, 2, ns)
+		exit, err := ls.handle(err)
+		return !exit, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ls.result(), nil
+}
+
+// evalControlFlow (break [値])、(continue [値])を評価し、制御の移動を表すcontrolFlowをエラーとして返す。
+func evalControlFlow(lst *parser.List, ns *Namespace, kind int) (interface{}, error) {
+	if lst.Len() > 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	cf := &controlFlow{kind, nil, false, lst.Position()}
+	if lst.Len() == 2 {
+		v, err := EvalElement(lst.ElementAt(1), ns)
+		if err != nil {
+			return nil, err
+		}
+		cf.value, cf.hasValue = v, true
+	}
+	return nil, cf
+}
+
+func breakBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalControlFlow(lst, ns, flowBreak)
+}
+
+func continueBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalControlFlow(lst, ns, flowContinue)
+}
+
+// returnBody (return 値) 最も内側のユーザー定義関数の呼び出しから値を返す。
+func returnBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	return evalControlFlow(lst, ns, flowReturn)
+}
+
+// RegisterLoop ループに関する拡張関数を登録する。
+func RegisterLoop(ns *Namespace) {
+	ns.RegisterExtension(forSymbol, nil, forBody)
+	ns.RegisterExtension(foreachSymbol, nil, foreachBody)
+	ns.RegisterExtension(breakSymbol, nil, breakBody)
+	ns.RegisterExtension(continueSymbol, nil, continueBody)
+	ns.RegisterExtension(returnSymbol, nil, returnBody)
+}
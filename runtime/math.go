@@ -416,7 +416,7 @@ func isInfBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, err
 		return 0, berr
 	}
 	r := math.IsInf(a, int(b))
-	return BoolToInt(r), nil
+	return BoolValue(r, ns), nil
 }
 
 func isNaNBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -428,7 +428,7 @@ func isNaNBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, err
 		return math.NaN(), err
 	}
 	r := math.IsNaN(a)
-	return BoolToInt(r), nil
+	return BoolValue(r, ns), nil
 }
 
 func j0Body(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -677,7 +677,7 @@ func signbitBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, e
 		return math.NaN(), aerr
 	}
 	r := math.Signbit(a)
-	return BoolToInt(r), nil
+	return BoolValue(r, ns), nil
 }
 
 func sinBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -785,17 +785,17 @@ func ynBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error)
 
 // RegisterMath stに演算子のシンボルを、nsに演算子に対応する拡張関数をそれぞれ登録する。
 func RegisterMath(ns *Namespace) {
-	ns.Set(ns.GetSymbolID(eSymbol), float64(math.E))
-	ns.Set(ns.GetSymbolID(piSymbol), float64(math.Pi))
-	ns.Set(ns.GetSymbolID(phiSymbol), float64(math.Phi))
-	ns.Set(ns.GetSymbolID(sqrt2Symbol), float64(math.Sqrt2))
-	ns.Set(ns.GetSymbolID(sqrtESymbol), float64(math.SqrtE))
-	ns.Set(ns.GetSymbolID(sqrtPiSymbol), float64(math.SqrtPi))
-	ns.Set(ns.GetSymbolID(sqrtPhiSymbol), float64(math.SqrtPhi))
-	ns.Set(ns.GetSymbolID(ln2Symbol), float64(math.Ln2))
-	ns.Set(ns.GetSymbolID(log2ESymbol), float64(math.Log2E))
-	ns.Set(ns.GetSymbolID(ln10Symbol), float64(math.Ln10))
-	ns.Set(ns.GetSymbolID(log10ESymbol), float64(math.Log10E))
+	ns.RegisterConstant(eSymbol, float64(math.E))
+	ns.RegisterConstant(piSymbol, float64(math.Pi))
+	ns.RegisterConstant(phiSymbol, float64(math.Phi))
+	ns.RegisterConstant(sqrt2Symbol, float64(math.Sqrt2))
+	ns.RegisterConstant(sqrtESymbol, float64(math.SqrtE))
+	ns.RegisterConstant(sqrtPiSymbol, float64(math.SqrtPi))
+	ns.RegisterConstant(sqrtPhiSymbol, float64(math.SqrtPhi))
+	ns.RegisterConstant(ln2Symbol, float64(math.Ln2))
+	ns.RegisterConstant(log2ESymbol, float64(math.Log2E))
+	ns.RegisterConstant(ln10Symbol, float64(math.Ln10))
+	ns.RegisterConstant(log10ESymbol, float64(math.Log10E))
 	ns.RegisterExtension(absSymbol, nil, absBody)
 	ns.RegisterExtension(absSymbol, nil, absBody)
 	ns.RegisterExtension(acosSymbol, nil, acosBody)
@@ -1,21 +1,46 @@
 package runtime
 
 import (
-	"fmt"
 	"reflect"
+	"sort"
+	"time"
 
 	"github.com/healthy-tiger/scalc/parser"
 )
 
+// 名前空間に関するエラーコード
+var (
+	ErrorTheNamespaceIsReadOnly int
+	ErrorTheSymbolIsReadOnly    int
+)
+
+func init() {
+	ErrorTheNamespaceIsReadOnly = RegisterEvalError("The namespace is read-only")
+	ErrorTheSymbolIsReadOnly = RegisterEvalError("The symbol %v is read-only")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheNamespaceIsReadOnly, "名前空間は読み取り専用です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheSymbolIsReadOnly, "シンボル %v は読み取り専用です")
+}
+
 // Namespace シンボルと値のマップ
 type Namespace struct {
 	symtbl   *parser.SymbolTable // ルートの名前空間の場合のみ非nilになる。
 	root     *Namespace
 	parent   *Namespace
-	bindings map[parser.SymbolID]interface{} // string, int64, float64, *Functionのいれずれか
+	base     *Namespace                      // ルートの名前空間の場合のみ、読み取り専用の基底の名前空間を持つことができる。
+	frozen   bool                            // trueの場合は読み取り専用
+	bindings map[parser.SymbolID]interface{} // nil, string, int64, float64, bool, time.Duration, []interface{}, *Functionのいれずれか
+	readonly map[parser.SymbolID]bool        // constで束縛されたシンボル
+	builtins map[parser.SymbolID]bool        // RegisterExtension、RegisterConstantで登録されたシンボル
+	locked   bool                            // trueの場合はbuiltinsのシンボルも読み取り専用として扱う
+	config   *Config                         // ルートの名前空間の場合のみ非nilになる。
+	regexps  *regexpCache                    // ルートの名前空間の場合のみ非nilになる。
+	clock    func() time.Time                // ルートの名前空間の場合のみ、with-nowの評価中は非nilになる。
+	location *time.Location                  // ルートの名前空間の場合のみ、with-time-zoneの評価中は非nilになる。
 }
 
 // Get nsからシンボルID idに対応する値を取得する。
+// 親の名前空間をたどっても見つからない場合は、ルートの名前空間の基底の名前空間から探す。
 func (ns *Namespace) Get(id parser.SymbolID) (interface{}, bool) {
 	n := ns
 	for n != nil {
@@ -23,19 +48,189 @@ func (ns *Namespace) Get(id parser.SymbolID) (interface{}, bool) {
 		if ok {
 			return v, true
 		}
-		n = n.parent
+		if n.parent == nil && n.base != nil {
+			n = n.base
+		} else {
+			n = n.parent
+		}
 	}
 	return nil, false
 }
 
 // Set nsにシンボルID idに対応する値を格納する。
-func (ns *Namespace) Set(id parser.SymbolID, value interface{}) {
+// 格納できない型の値の場合や、ns自身のidの束縛が読み取り専用の場合、idがロックされた組み込みの値の場合はエラーを返す。
+// 親の名前空間のconstの束縛は、nsの束縛で隠すことができる。ロックされた組み込みの値は関数の中などでも隠すことはできない。
+func (ns *Namespace) Set(id parser.SymbolID, value interface{}) error {
+	if err := ns.checkWritable(id); err != nil {
+		return err
+	}
 	switch value.(type) {
-	case int64, float64, string, *Function:
+	case nil, int64, float64, string, bool, time.Duration, []interface{}, *Function:
 		ns.bindings[id] = value
+		return nil
 	default:
-		panic(fmt.Sprintf("Invalid Type of symbol %v", reflect.TypeOf(value)))
+		return NewEvalError(parser.Position{}, ErrorInvalidValueType, reflect.TypeOf(value))
+	}
+}
+
+// SetConst nsにシンボルID idに対応する値を読み取り専用で格納する。
+func (ns *Namespace) SetConst(id parser.SymbolID, value interface{}) error {
+	if err := ns.Set(id, value); err != nil {
+		return err
+	}
+	if ns.readonly == nil {
+		ns.readonly = make(map[parser.SymbolID]bool)
+	}
+	ns.readonly[id] = true
+	return nil
+}
+
+// IsReadOnly idに最も近い束縛が読み取り専用の場合、またはidがロックされた組み込みの値の場合はtrueを返す。
+func (ns *Namespace) IsReadOnly(id parser.SymbolID) bool {
+	if ns.isLockedBuiltin(id) {
+		return true
 	}
+	n := ns.DefinedIn(id)
+	return n != nil && n.readonly[id]
+}
+
+// isLockedBuiltin idがnsのルートの名前空間またはその基底の名前空間に登録された組み込みの値で、ロックされている場合はtrueを返す。
+// ルートの名前空間でLockBuiltinsを呼び出した場合は、基底の名前空間の組み込みの値もロックされる。
+func (ns *Namespace) isLockedBuiltin(id parser.SymbolID) bool {
+	locked := false
+	for r := ns.Root(); r != nil; {
+		locked = locked || r.locked
+		if locked && r.builtins[id] {
+			return true
+		}
+		if r.base == nil {
+			break
+		}
+		r = r.base.Root()
+	}
+	return false
+}
+
+// checkWritable nsにシンボルID idの値を格納できるか確認する。constの束縛はns自身の束縛だけを調べ、
+// ロックされた組み込みの値はルートの名前空間とその基底の名前空間を調べる。
+func (ns *Namespace) checkWritable(id parser.SymbolID) error {
+	if ns.frozen {
+		return NewEvalError(parser.Position{}, ErrorTheNamespaceIsReadOnly)
+	}
+	if ns.readonly[id] || ns.isLockedBuiltin(id) {
+		return NewEvalError(parser.Position{}, ErrorTheSymbolIsReadOnly, symbolNameOrID(ns, id))
+	}
+	return nil
+}
+
+// symbolNameOrID エラーメッセージに表示するためにシンボル名を返す。シンボル名が見つからない場合はIDを返す。
+func symbolNameOrID(ns *Namespace, id parser.SymbolID) interface{} {
+	if sn, err := ns.GetSymbolName(id); err == nil {
+		return sn
+	}
+	return id
+}
+
+// LockBuiltins RegisterExtension、RegisterConstantで登録した（及び今後登録する）シンボルを、nsのルートの名前空間とその子孫の名前空間で読み取り専用にする。
+// 基底の名前空間に登録されたシンボルも対象にする。
+func (ns *Namespace) LockBuiltins() {
+	ns.Root().locked = true
+}
+
+// Delete nsからシンボルID idの束縛を削除する。親の名前空間の束縛は削除しない。
+func (ns *Namespace) Delete(id parser.SymbolID) error {
+	if !ns.IsDefinedLocally(id) {
+		return nil
+	}
+	if err := ns.checkWritable(id); err != nil {
+		return err
+	}
+	delete(ns.bindings, id)
+	return nil
+}
+
+// IsDefinedLocally シンボルID idがns自身に束縛されている場合はtrueを返す。
+func (ns *Namespace) IsDefinedLocally(id parser.SymbolID) bool {
+	_, ok := ns.bindings[id]
+	return ok
+}
+
+// DefinedIn シンボルID idが束縛されている名前空間を返す。どこにも束縛されていない場合はnilを返す。
+func (ns *Namespace) DefinedIn(id parser.SymbolID) *Namespace {
+	n := ns
+	for n != nil {
+		if _, ok := n.bindings[id]; ok {
+			return n
+		}
+		if n.parent == nil && n.base != nil {
+			n = n.base
+		} else {
+			n = n.parent
+		}
+	}
+	return nil
+}
+
+// Len ns自身に束縛されているシンボルの数を返す。
+func (ns *Namespace) Len() int {
+	return len(ns.bindings)
+}
+
+// Range ns自身に束縛されているシンボルと値の組をシンボルIDの順にfに渡す。fがfalseを返した時点で終了する。
+func (ns *Namespace) Range(f func(id parser.SymbolID, value interface{}) bool) {
+	ids := make([]parser.SymbolID, 0, len(ns.bindings))
+	for id := range ns.bindings {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if !f(id, ns.bindings[id]) {
+			return
+		}
+	}
+}
+
+// Clone nsとその親の名前空間を複製する。基底の名前空間は複製せずに共有する。
+// 複製した名前空間は読み取り専用ではない。
+func (ns *Namespace) Clone() *Namespace {
+	var parent *Namespace
+	if ns.parent != nil {
+		parent = ns.parent.Clone()
+	}
+	c := NewNamespace(parent)
+	c.symtbl = ns.symtbl
+	c.config = ns.config
+	c.regexps = ns.regexps
+	c.clock = ns.clock
+	c.location = ns.location
+	c.base = ns.base
+	c.locked = ns.locked
+	for id, v := range ns.bindings {
+		c.bindings[id] = v
+	}
+	if ns.readonly != nil {
+		c.readonly = make(map[parser.SymbolID]bool)
+		for id := range ns.readonly {
+			c.readonly[id] = true
+		}
+	}
+	if ns.builtins != nil {
+		c.builtins = make(map[parser.SymbolID]bool)
+		for id := range ns.builtins {
+			c.builtins[id] = true
+		}
+	}
+	return c
+}
+
+// Freeze nsを読み取り専用にする。読み取り専用の名前空間は複数のゴルーチンから同時に参照できる。
+func (ns *Namespace) Freeze() {
+	ns.frozen = true
+}
+
+// IsFrozen nsが読み取り専用の場合はtrueを返す。
+func (ns *Namespace) IsFrozen() bool {
+	return ns.frozen
 }
 
 // Parent nsの親の名前空間を返す。
@@ -43,6 +238,11 @@ func (ns *Namespace) Parent() *Namespace {
 	return ns.parent
 }
 
+// Base nsのルートの名前空間の基底の名前空間を返す。
+func (ns *Namespace) Base() *Namespace {
+	return ns.Root().base
+}
+
 // GetSymbolID シンボルを新たに登録する。
 func (ns *Namespace) GetSymbolID(name string) parser.SymbolID {
 	return ns.Root().symtbl.GetSymbolID(name)
@@ -63,9 +263,23 @@ func (ns *Namespace) Root() *Namespace {
 
 // RegisterExtension 拡張関数を登録する。必ず名前空間のルートに対して登録を行う。
 func (ns *Namespace) RegisterExtension(symbolName string, extobj interface{}, extbody func(interface{}, *parser.List, *Namespace) (interface{}, error)) parser.SymbolID {
+	return ns.RegisterConstant(symbolName, &Function{nil, nil, extobj, extbody, symbolName, nil})
+}
+
+// RegisterConstant 組み込みの値を登録する。必ず名前空間のルートに対して登録を行う。
+// 組み込みの値はLockBuiltinsを呼び出すと読み取り専用になる。無効な型の値の場合はInvalidSymbolIDを返す。
+func (ns *Namespace) RegisterConstant(symbolName string, value interface{}) parser.SymbolID {
+	if !isValidType(value) {
+		return parser.InvalidSymbolID
+	}
 	root := ns.Root()
 	sid := root.symtbl.GetSymbolID(symbolName)
-	root.Set(sid, &Function{nil, nil, extobj, extbody})
+	// 組み込みの値の再登録はホストからの操作なので、読み取り専用かどうかは確認しない。
+	root.bindings[sid] = value
+	if root.builtins == nil {
+		root.builtins = make(map[parser.SymbolID]bool)
+	}
+	root.builtins[sid] = true
 	return sid
 }
 
@@ -79,12 +293,27 @@ func NewNamespace(parent *Namespace) *Namespace {
 			p = p.parent
 		}
 	}
-	return &Namespace{nil, p, parent, make(map[parser.SymbolID]interface{})}
+	return &Namespace{nil, p, parent, nil, false, make(map[parser.SymbolID]interface{}), nil, nil, false, nil, nil, nil, nil}
 }
 
 // NewRootNamespace 新しく最上位の名前空間を作る
 func NewRootNamespace(st *parser.SymbolTable) *Namespace {
 	r := NewNamespace(nil)
 	r.symtbl = st
+	r.config = &Config{}
+	r.regexps = newRegexpCache()
+	return r
+}
+
+// NewRootNamespaceWithBase baseを基底とする新しい最上位の名前空間を作る。
+// 基底の名前空間は参照されるだけなので、Freezeで読み取り専用にしておけば複数の名前空間から同時に共有できる。
+// シンボルテーブルと正規表現のキャッシュはbaseのルートの名前空間のものを共有する。
+// 設定はbaseのルートの名前空間の設定の複製を持つので、変更しても他の名前空間には影響しない。
+func NewRootNamespaceWithBase(base *Namespace) *Namespace {
+	r := NewRootNamespace(base.Root().symtbl)
+	config := *base.Root().config
+	r.config = &config
+	r.regexps = base.Root().regexps
+	r.base = base
 	return r
 }
@@ -0,0 +1,208 @@
+package runtime_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/healthy-tiger/scalc/parser"
+	"github.com/healthy-tiger/scalc/runtime"
+)
+
+func TestSharedBaseNamespace(t *testing.T) {
+	st := parser.NewSymbolTable()
+	std := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(std)
+	std.Freeze()
+	if err := std.Set(std.GetSymbolID("x"), int64(1)); err == nil {
+		t.Error("A frozen namespace was modified")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ns := runtime.NewRootNamespaceWithBase(std)
+			// 設定の変更は他の名前空間に影響しない。
+			promote := i%2 == 0
+			ns.Config().NumericPromotion = promote
+			ns.Config().Calendar = fmt.Sprintf("calendar%d", i)
+			src := fmt.Sprintf(`(set x%d %d) (set f (func (a) (* a 2))) (f (+ x%d 1))`, i, i, i)
+			lists, err := parser.ParseString("TestSharedBaseNamespace", st, src)
+			if err != nil {
+				errs <- err
+				return
+			}
+			var r interface{}
+			for _, l := range lists {
+				r, err = runtime.EvalList(l, ns)
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+			if r != int64((i+1)*2) {
+				errs <- fmt.Errorf("Unexpected result %v", r)
+			}
+			lists, _ = parser.ParseString("TestSharedBaseNamespace", st, `(+ 1 2.5)`)
+			if _, err := runtime.EvalList(lists[0], ns); (err == nil) != promote || ns.Config().NumericPromotion != promote {
+				errs <- fmt.Errorf("The config was changed by another namespace: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if std.Config().NumericPromotion || std.Config().Calendar != "" {
+		t.Errorf("The config of the base namespace was modified: %+v", *std.Config())
+	}
+}
+
+func evalAll(t *testing.T, name string, st *parser.SymbolTable, ns *runtime.Namespace, src string) interface{} {
+	lists, err := parser.ParseString(name, st, src)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	var r interface{}
+	for _, l := range lists {
+		r, err = runtime.EvalList(l, ns)
+		if err != nil {
+			t.Fatalf("Eval error: %v", err)
+		}
+	}
+	return r
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	scratch := runtime.NewNamespace(ns)
+	evalAll(t, "TestSnapshotRestore", st, scratch, `(set a 1) (set b 2.5) (set c "x\"y") (set f (func (x) (+ x 1))) (set g abs) (set h nil) (set i true) (set dur 1h30m)`)
+
+	snap, err := scratch.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := scratch.Clone()
+	evalAll(t, "TestSnapshotRestore", st, scratch, `(set a 10) (set d 4)`)
+	if !scratch.IsDefinedLocally(st.GetSymbolID("d")) || clone.IsDefinedLocally(st.GetSymbolID("d")) {
+		t.Error("The cloned namespace shares bindings")
+	}
+
+	var loaded runtime.Snapshot
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if err := scratch.Restore(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if scratch.IsDefinedLocally(st.GetSymbolID("d")) {
+		t.Error("The binding was not rolled back")
+	}
+	if r := evalAll(t, "TestSnapshotRestore", st, scratch, `(str (f 1) b c (g -1.0) h i dur)`); r != `22.5x"y1niltrue1h30m0s` {
+		t.Errorf("Unexpected result %v", r)
+	}
+	if scratch.DefinedIn(st.GetSymbolID("abs")) != ns {
+		t.Error("abs is not defined in the root namespace")
+	}
+	if err := scratch.Delete(st.GetSymbolID("a")); err != nil || scratch.IsDefinedLocally(st.GetSymbolID("a")) {
+		t.Error("The binding was not deleted")
+	}
+}
+
+func TestSaveLoadSession(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	evalAll(t, "TestSaveLoadSession", st, ns, `(set a 1) (set b 2.0) (set c "tab\tquote\"") (set f (func (x y) (+ x [* y 2]))) (set g abs) (set Pi 3.0) (set h nil) (set l (list 1 "a" (list 2.5 false) 2m))`)
+
+	var buf bytes.Buffer
+	n, err := runtime.SaveSession(ns, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 {
+		t.Errorf("Unexpected number of bindings %d:\n%s", n, buf.String())
+	}
+
+	st2 := parser.NewSymbolTable()
+	ns2 := runtime.NewRootNamespace(st2)
+	runtime.MakeDefaultNamespace(ns2)
+	if _, err := runtime.LoadSession(ns2, "TestSaveLoadSession", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if r := evalAll(t, "TestSaveLoadSession", st2, ns2, `(str a b c (f 1 2) (g -1.0) Pi h l)`); r != "12tab\tquote\"513nil(1 \"a\" (2.5 false) 2m0s)" {
+		t.Errorf("Unexpected result %v", r)
+	}
+
+	if _, err := runtime.LoadSession(ns2, "TestSaveLoadSession", strings.NewReader(`(print "x")`)); err == nil {
+		t.Error("A non-set expression was loaded")
+	}
+
+	runtime.RegisterSession(ns2)
+	for _, src := range []string{
+		`(set x (save-session "TestSaveLoadSession.out"))`,
+		`(set x (list 1 (list (print "x"))))`,
+		`(const x (+ 1 2))`,
+		`(set x ((func () 1)))`,
+	} {
+		if _, err := runtime.LoadSession(ns2, "TestSaveLoadSession", strings.NewReader(src)); err == nil {
+			t.Errorf("A session entry with a call was loaded: %s", src)
+		} else if e, ok := err.(*runtime.EvalError); !ok || e.ID != runtime.ErrorInvalidSessionEntry {
+			t.Errorf("Unexpected error %v: %s", err, src)
+		}
+		if ns2.IsDefinedLocally(st2.GetSymbolID("x")) {
+			t.Errorf("The value was bound: %s", src)
+		}
+	}
+	if _, err := os.Stat("TestSaveLoadSession.out"); err == nil {
+		os.Remove("TestSaveLoadSession.out")
+		t.Error("The call in the session file was evaluated")
+	}
+}
+
+func TestSaveClosure(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	evalAll(t, "TestSaveClosure", st, ns, `(set k 10) (set addk (lambda (x) (+ x k)))`)
+
+	var buf bytes.Buffer
+	if _, err := runtime.SaveSession(ns, &buf); err != nil {
+		t.Fatal(err)
+	}
+	st2 := parser.NewSymbolTable()
+	ns2 := runtime.NewRootNamespace(st2)
+	runtime.MakeDefaultNamespace(ns2)
+	if _, err := runtime.LoadSession(ns2, "TestSaveClosure", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if r := evalAll(t, "TestSaveClosure", st2, ns2, `(addk 1)`); r != int64(11) {
+		t.Errorf("Unexpected result %v", r)
+	}
+
+	evalAll(t, "TestSaveClosure", st, ns, `(set adder (func (n) (lambda (x) (+ x n)))) (set add2 (adder 2))`)
+	if _, err := runtime.SaveSession(ns, &buf); err == nil {
+		t.Error("A lambda that captures a local variable was saved")
+	} else if e, ok := err.(*runtime.EvalError); !ok || e.ID != runtime.ErrorCannotSaveAClosure {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if _, err := ns.Snapshot(); err == nil {
+		t.Error("A lambda that captures a local variable was saved in a snapshot")
+	} else if e, ok := err.(*runtime.EvalError); !ok || e.ID != runtime.ErrorCannotSaveAClosure {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package runtime
+
+import (
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	nilSymbol      = "nil"
+	isNilSymbol    = "is-nil"
+	coalesceSymbol = "coalesce"
+	defaultSymbol  = "default"
+)
+
+// isNilBody 引数がすべてnilの場合に真を返す。
+func isNilBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	params := make([]interface{}, lst.Len())
+	for i := 1; i < lst.Len(); i++ {
+		p, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = p
+	}
+
+	for i := 1; i < lst.Len(); i++ {
+		if params[i] != nil {
+			return BoolValue(false, ns), nil
+		}
+	}
+	return BoolValue(true, ns), nil
+}
+
+// coalesceBody 引数を順に評価し、最初のnilでない値を返す。それ以降の引数は評価しない。すべてnilの場合はnilを返す。
+func coalesceBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
+	}
+	for i := 1; i < lst.Len(); i++ {
+		v, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// defaultBody (default 値 既定値)の形式で、値がnilの場合のみ既定値を評価して返す。
+func defaultBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	return coalesceBody(nil, lst, ns)
+}
+
+// RegisterNilType nsにnilのシンボルと、nilを扱う拡張関数を登録する。
+func RegisterNilType(ns *Namespace) {
+	ns.RegisterConstant(nilSymbol, nil)
+	ns.RegisterExtension(isNilSymbol, nil, isNilBody)
+	ns.RegisterExtension(coalesceSymbol, nil, coalesceBody)
+	ns.RegisterExtension(defaultSymbol, nil, defaultBody)
+}
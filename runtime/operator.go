@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/healthy-tiger/scalc/parser"
 )
@@ -33,6 +35,7 @@ const (
 	isStrSymbol      = "is-str"
 	isIntSymbol      = "is-int"
 	isFloatSymbol    = "is-float"
+	isBoolSymbol     = "is-bool"
 )
 
 // 演算子に関するエラーコード
@@ -42,6 +45,7 @@ var (
 	ErrorOperantsMustBeOfIntegerType    int
 	ErrorOperantsMustBeOfFloatType      int
 	ErrorOperantsMustBeOfStringType     int
+	ErrorOperantsMustBeOfBoolType       int
 	ErrorDivisionByZero                 int
 	ErrorAllOperantsMustBeOfTheSameType int
 	ErrorNonArithmeticDataType          int
@@ -53,9 +57,20 @@ func init() {
 	ErrorOperantsMustBeOfIntegerType = RegisterEvalError("Operants must be of integer type: %v")
 	ErrorOperantsMustBeOfFloatType = RegisterEvalError("Operants must be of float type: %v")
 	ErrorOperantsMustBeOfStringType = RegisterEvalError("Operants must be of string type: %v")
+	ErrorOperantsMustBeOfBoolType = RegisterEvalError("Operants must be of bool type: %v")
 	ErrorDivisionByZero = RegisterEvalError("Division by zero")
 	ErrorAllOperantsMustBeOfTheSameType = RegisterEvalError("All operants must be of the same type")
 	ErrorNonArithmeticDataType = RegisterEvalError("Non-arithmetic data type: '%v)")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTypeMissmatch, "型が一致しません (%v, %v)")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorOperantsMustBeNumeric, "オペラントは数値でなければなりません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorOperantsMustBeOfIntegerType, "オペラントは整数型でなければなりません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorOperantsMustBeOfFloatType, "オペラントは浮動小数点数型でなければなりません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorOperantsMustBeOfStringType, "オペラントは文字列型でなければなりません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorOperantsMustBeOfBoolType, "オペラントは真偽値型でなければなりません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorDivisionByZero, "ゼロで除算しました")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorAllOperantsMustBeOfTheSameType, "すべてのオペラントは同じ型でなければなりません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorNonArithmeticDataType, "算術演算できないデータ型です: '%v)")
 }
 
 func isArithmeticDataType(v *interface{}) bool {
@@ -67,12 +82,24 @@ func isArithmeticDataType(v *interface{}) bool {
 	}
 }
 
+// isAdditiveDataType 加算と減算ができるデータ型の場合はtrueを返す。数値の他に時間の長さを加減算できる。
+func isAdditiveDataType(v *interface{}) bool {
+	if _, ok := (*v).(time.Duration); ok {
+		return true
+	}
+	return isArithmeticDataType(v)
+}
+
 func isSameType(a *interface{}, b *interface{}) bool {
 	switch (*a).(type) {
 	case int64:
 		if _, ok := (*b).(int64); ok {
 			return true
 		}
+	case time.Duration:
+		if _, ok := (*b).(time.Duration); ok {
+			return true
+		}
 	case float64:
 		if _, ok := (*b).(float64); ok {
 			return true
@@ -85,13 +112,56 @@ func isSameType(a *interface{}, b *interface{}) bool {
 		if _, ok := (*b).(string); ok {
 			return true
 		}
-	default:
-		panic("Unexpected Data Type.")
+	case bool:
+		if _, ok := (*b).(bool); ok {
+			return true
+		}
+	case []interface{}:
+		if _, ok := (*b).([]interface{}); ok {
+			return true
+		}
+	case nil:
+		return *b == nil
 	}
+	// 想定外の型の場合は同じ型とみなさない。
 	return false
 }
 
-// Eval オペラントの評価結果がすべてint64、すべてfloat64の場合にそれらのすべてを加算（または連結）した結果を返す。
+// numericRank 数値の型の順位を返す。順位の低い型の値は高い型に昇格できる。数値でない場合は-1を返す。
+func numericRank(v interface{}) int {
+	switch v.(type) {
+	case int64:
+		return 0
+	case float64:
+		return 1
+	}
+	return -1
+}
+
+// promoteNumber 数値vを順位rankの型に変換する。
+func promoteNumber(v interface{}, rank int) interface{} {
+	if i, ok := v.(int64); ok && rank == 1 {
+		return float64(i)
+	}
+	return v
+}
+
+// promoteNumbers 数値の昇格が有効な場合、aとbのうち順位の低い方を高い方の型に変換して返す。
+func promoteNumbers(a, b interface{}, ns *Namespace) (interface{}, interface{}) {
+	if !ns.Config().NumericPromotion {
+		return a, b
+	}
+	ra, rb := numericRank(a), numericRank(b)
+	if ra < 0 || rb < 0 || ra == rb {
+		return a, b
+	}
+	if ra < rb {
+		return promoteNumber(a, rb), b
+	}
+	return a, promoteNumber(b, ra)
+}
+
+// Eval オペラントの評価結果がすべてint64、すべてfloat64、すべてtime.Durationの場合にそれらのすべてを加算（または連結）した結果を返す。
 func addBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
 	if lst.Len() < 3 {
 		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
@@ -107,14 +177,15 @@ func addBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 	}
 
 	result := params[1]
-	if !isArithmeticDataType(&result) {
+	if !isAdditiveDataType(&result) {
 		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorNonArithmeticDataType, reflect.TypeOf(result))
 	}
 	for i := 2; i < lst.Len(); i++ {
 		b := params[i]
-		if !isArithmeticDataType(&b) {
+		if !isAdditiveDataType(&b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorNonArithmeticDataType, reflect.TypeOf(b))
 		}
+		result, b = promoteNumbers(result, b, ns)
 		if !isSameType(&result, &b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorTypeMissmatch, reflect.TypeOf(result), reflect.TypeOf(b))
 		}
@@ -123,12 +194,20 @@ func addBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 			result = v + b.(int64)
 		case float64:
 			result = v + b.(float64)
+		case time.Duration:
+			// time.Durationで表せない長さは、符号が反転したりしないようにエラーにする。
+			d := b.(time.Duration)
+			r := v + d
+			if (d > 0 && r < v) || (d < 0 && r > v) {
+				return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorDurationOutOfRange, fmt.Sprint(v, " + ", d))
+			}
+			result = r
 		}
 	}
 	return result, nil
 }
 
-// Eval オペラントの評価結果がすべてint64またはすべてfloat64の値の場合にそれらすべてを減算した結果を返す。
+// Eval オペラントの評価結果がすべてint64、すべてfloat64、すべてtime.Durationの値の場合にそれらすべてを減算した結果を返す。
 func subBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
 	if lst.Len() < 3 {
 		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
@@ -144,14 +223,15 @@ func subBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 	}
 
 	result := params[1]
-	if !isArithmeticDataType(&result) {
+	if !isAdditiveDataType(&result) {
 		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorNonArithmeticDataType, reflect.TypeOf(result))
 	}
 	for i := 2; i < lst.Len(); i++ {
 		b := params[i]
-		if !isArithmeticDataType(&b) {
+		if !isAdditiveDataType(&b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorNonArithmeticDataType, reflect.TypeOf(b))
 		}
+		result, b = promoteNumbers(result, b, ns)
 		if !isSameType(&result, &b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorTypeMissmatch, reflect.TypeOf(result), reflect.TypeOf(b))
 		}
@@ -160,6 +240,13 @@ func subBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 			result = v - b.(int64)
 		case float64:
 			result = v - b.(float64)
+		case time.Duration:
+			d := b.(time.Duration)
+			r := v - d
+			if (d > 0 && r > v) || (d < 0 && r < v) {
+				return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorDurationOutOfRange, fmt.Sprint(v, " - ", d))
+			}
+			result = r
 		}
 	}
 	return result, nil
@@ -189,6 +276,7 @@ func mulBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 		if !isArithmeticDataType(&b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorNonArithmeticDataType, reflect.TypeOf(b))
 		}
+		result, b = promoteNumbers(result, b, ns)
 		if !isSameType(&result, &b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorTypeMissmatch, reflect.TypeOf(result), reflect.TypeOf(b))
 		}
@@ -226,6 +314,7 @@ func divBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 		if !isArithmeticDataType(&b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorNonArithmeticDataType, reflect.TypeOf(b))
 		}
+		result, b = promoteNumbers(result, b, ns)
 		if !isSameType(&result, &b) {
 			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorTypeMissmatch, reflect.TypeOf(result), reflect.TypeOf(b))
 		}
@@ -282,15 +371,19 @@ func eqBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error)
 
 	fst := params[1]
 	for i := 2; i < lst.Len(); i++ {
-		b := params[i]
-		if !isSameType(&fst, &b) {
-			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorTypeMissmatch, reflect.TypeOf(fst), reflect.TypeOf(b))
+		a, b := promoteNumbers(fst, params[i], ns)
+		// nilとの比較は型が異なってもエラーにしない。
+		if (a == nil || b == nil) && a != b {
+			return BoolValue(false, ns), nil
+		}
+		if !isSameType(&a, &b) {
+			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(b))
 		}
-		if fst != b {
-			return int64(0), nil
+		if !valuesEqual(a, b) {
+			return BoolValue(false, ns), nil
 		}
 	}
-	return int64(1), nil
+	return BoolValue(true, ns), nil
 }
 
 func bitwiseANDbody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -437,124 +530,100 @@ func rShiftBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, er
 	return result, nil
 }
 
-func ltBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	// オペラントは2つしか許容しない
-	if lst.Len() != 3 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
-	}
-	// 引数をすべて評価する。
-	pa, err := EvalElement(lst.ElementAt(1), ns)
-	if err != nil {
-		return nil, err
-	}
-	pb, err := EvalElement(lst.ElementAt(2), ns)
-	if err != nil {
-		return nil, err
-	}
-	switch a := pa.(type) {
+// compareValues 同じ型の数値または文字列のaとbを比較し、a<bなら負、a==bなら0、a>bなら正の値を返す。
+// 文字列はnsの設定の照合順序で比較する。NaNとの比較のように順序付けできない場合はokにfalseを返す。
+// paとpbはそれぞれのオペラントのエラーの位置として使う。
+func compareValues(a, b interface{}, pa, pb parser.Position, ns *Namespace) (c int, ok bool, err error) {
+	switch av := a.(type) {
 	case int64:
-		if b, ok := pb.(int64); ok {
-			return BoolToInt(a < b), nil
+		if bv, ok := b.(int64); ok {
+			if av < bv {
+				return -1, true, nil
+			} else if av > bv {
+				return 1, true, nil
+			}
+			return 0, true, nil
 		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
 	case float64:
-		if b, ok := pb.(float64); ok {
-			return BoolToInt(a < b), nil
+		if bv, ok := b.(float64); ok {
+			if av < bv {
+				return -1, true, nil
+			} else if av > bv {
+				return 1, true, nil
+			} else if av == bv {
+				return 0, true, nil
+			}
+			return 0, false, nil
+		}
+	case time.Duration:
+		if bv, ok := b.(time.Duration); ok {
+			if av < bv {
+				return -1, true, nil
+			} else if av > bv {
+				return 1, true, nil
+			}
+			return 0, true, nil
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			if ns.Config().Collation == CollationNatural {
+				return compareRunes(toNaturalString(av), toNaturalString(bv)), true, nil
+			}
+			return strings.Compare(av, bv), true, nil
 		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
 	default:
-		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorNonArithmeticDataType, pa)
+		return 0, false, NewEvalError(pa, ErrorNonArithmeticDataType, a)
 	}
+	switch b.(type) {
+	case int64, float64, string, time.Duration:
+	default:
+		return 0, false, NewEvalError(pb, ErrorNonArithmeticDataType, b)
+	}
+	return 0, false, NewEvalError(pb, ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(b))
 }
 
-func lteBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	// オペラントは2つしか許容しない
-	if lst.Len() != 3 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+// compareBody (演算子 a b c ...)の形式のlstを評価し、隣り合うすべてのオペラントの比較結果cがtestを満たす場合に真を返す。
+// オペラントは数値または文字列で、(< a b c)は(and (< a b) (< b c))と同じ意味になる。
+func compareBody(lst *parser.List, ns *Namespace, test func(c int) bool) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
 	}
 	// 引数をすべて評価する。
-	pa, err := EvalElement(lst.ElementAt(1), ns)
-	if err != nil {
-		return nil, err
-	}
-	pb, err := EvalElement(lst.ElementAt(2), ns)
-	if err != nil {
-		return nil, err
-	}
-	switch a := pa.(type) {
-	case int64:
-		if b, ok := pb.(int64); ok {
-			return BoolToInt(a <= b), nil
+	params := make([]interface{}, lst.Len())
+	for i := 1; i < lst.Len(); i++ {
+		ev, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
 		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
-	case float64:
-		if b, ok := pb.(float64); ok {
-			return BoolToInt(a <= b), nil
+		params[i] = ev
+	}
+	result := true
+	for i := 2; i < lst.Len(); i++ {
+		a, b := promoteNumbers(params[i-1], params[i], ns)
+		c, ok, err := compareValues(a, b, lst.ElementAt(i-1).Position(), lst.ElementAt(i).Position(), ns)
+		if err != nil {
+			return nil, err
 		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
-	default:
-		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorNonArithmeticDataType, pa)
+		// 型の誤りを見逃さないように、結果が偽になっても残りのオペラントを確認する。
+		result = result && ok && test(c)
 	}
+	return BoolValue(result, ns), nil
+}
+
+func ltBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return compareBody(lst, ns, func(c int) bool { return c < 0 })
+}
+
+func lteBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return compareBody(lst, ns, func(c int) bool { return c <= 0 })
 }
 
 func gtBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	// オペラントは2つしか許容しない
-	if lst.Len() != 3 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
-	}
-	// 引数をすべて評価する。
-	pa, err := EvalElement(lst.ElementAt(1), ns)
-	if err != nil {
-		return nil, err
-	}
-	pb, err := EvalElement(lst.ElementAt(2), ns)
-	if err != nil {
-		return nil, err
-	}
-	switch a := pa.(type) {
-	case int64:
-		if b, ok := pb.(int64); ok {
-			return BoolToInt(a > b), nil
-		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
-	case float64:
-		if b, ok := pb.(float64); ok {
-			return BoolToInt(a > b), nil
-		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, a, pb)
-	default:
-		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorNonArithmeticDataType, pa)
-	}
+	return compareBody(lst, ns, func(c int) bool { return c > 0 })
 }
 
 func gteBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	// オペラントは2つしか許容しない
-	if lst.Len() != 3 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
-	}
-	// 引数をすべて評価する。
-	pa, err := EvalElement(lst.ElementAt(1), ns)
-	if err != nil {
-		return nil, err
-	}
-	pb, err := EvalElement(lst.ElementAt(2), ns)
-	if err != nil {
-		return nil, err
-	}
-	switch a := pa.(type) {
-	case int64:
-		if b, ok := pb.(int64); ok {
-			return BoolToInt(a >= b), nil
-		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
-	case float64:
-		if b, ok := pb.(float64); ok {
-			return BoolToInt(a >= b), nil
-		}
-		return nil, NewEvalError(lst.ElementAt(2).Position(), ErrorTypeMissmatch, reflect.TypeOf(a), reflect.TypeOf(pb))
-	default:
-		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorNonArithmeticDataType, pa)
-	}
+	return compareBody(lst, ns, func(c int) bool { return c >= 0 })
 }
 
 func notBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -566,13 +635,11 @@ func notBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 	if err != nil {
 		return nil, err
 	}
-	if b, ok := p.(int64); ok {
-		if b != 0 {
-			return 0, nil
-		}
-		return 1, nil
+	b, err := isTrue(p, lst.ElementAt(1).Position(), ns)
+	if err != nil {
+		return nil, err
 	}
-	return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorOperantsMustBeOfIntegerType, p)
+	return BoolValue(!b, ns), nil
 }
 
 func andBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -585,16 +652,15 @@ func andBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 		if err != nil {
 			return nil, err
 		}
-		bv, ok := ev.(int64)
-		if !ok {
-			// 評価結果がint64に変換できない場合はエラーになる。
-			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorOperantsMustBeOfIntegerType, ev)
+		bv, err := isTrue(ev, lst.ElementAt(i).Position(), ns)
+		if err != nil {
+			return nil, err
 		}
-		if bv == 0 {
-			return 0, nil
+		if !bv {
+			return BoolValue(false, ns), nil
 		}
 	}
-	return 1, nil
+	return BoolValue(true, ns), nil
 }
 
 func orBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -607,16 +673,15 @@ func orBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error)
 		if err != nil {
 			return nil, err
 		}
-		bv, ok := ev.(int64)
-		if !ok {
-			// 評価結果がint64に変換できない場合はエラーになる。
-			return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorOperantsMustBeOfIntegerType, ev)
+		bv, err := isTrue(ev, lst.ElementAt(i).Position(), ns)
+		if err != nil {
+			return nil, err
 		}
-		if bv != 0 {
-			return 1, nil
+		if bv {
+			return BoolValue(true, ns), nil
 		}
 	}
-	return 0, nil
+	return BoolValue(false, ns), nil
 }
 
 func strBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -629,20 +694,36 @@ func strBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 		if err != nil {
 			return nil, err
 		}
-		switch v := ev.(type) {
-		case int64:
-			result += fmt.Sprint(v)
-		case float64:
-			result += fmt.Sprint(v)
-		case string:
-			result += v
-		default:
+		s, ok := displayString(ev)
+		if !ok {
 			return nil, NewEvalError(lst.Position(), ErrorInvalidOperation)
 		}
+		result += s
 	}
 	return result, nil
 }
 
+// displayString strで連結する際の値vの文字列表現を返す。文字列に変換できない値の場合はfalseを返す。
+func displayString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case int64:
+		return fmt.Sprint(v), true
+	case float64:
+		return fmt.Sprint(v), true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Duration:
+		return v.String(), true
+	case nil:
+		return nilSymbol, true
+	case []interface{}:
+		return listString(v), true
+	}
+	return "", false
+}
+
 func intBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
 	if lst.Len() != 2 {
 		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
@@ -656,6 +737,8 @@ func intBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 		return v, nil
 	case float64:
 		return int64(v), nil
+	case bool:
+		return BoolToInt(v), nil
 	case string:
 		iv, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
@@ -680,6 +763,8 @@ func floatBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, err
 		return float64(v), nil
 	case float64:
 		return v, nil
+	case bool:
+		return float64(BoolToInt(v)), nil
 	case string:
 		fv, err := strconv.ParseFloat(v, 64)
 		if err != nil {
@@ -704,10 +789,10 @@ func isStrBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, err
 	for i := 1; i < lst.Len(); i++ {
 		_, ok := params[i].(string)
 		if !ok {
-			return BoolToInt(false), nil
+			return BoolValue(false, ns), nil
 		}
 	}
-	return BoolToInt(true), nil
+	return BoolValue(true, ns), nil
 }
 
 func isIntBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -723,10 +808,10 @@ func isIntBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, err
 	for i := 1; i < lst.Len(); i++ {
 		_, ok := params[i].(int64)
 		if !ok {
-			return BoolToInt(false), nil
+			return BoolValue(false, ns), nil
 		}
 	}
-	return BoolToInt(true), nil
+	return BoolValue(true, ns), nil
 }
 
 func isFloatBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -742,10 +827,29 @@ func isFloatBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, e
 	for i := 1; i < lst.Len(); i++ {
 		_, ok := params[i].(float64)
 		if !ok {
-			return BoolToInt(false), nil
+			return BoolValue(false, ns), nil
+		}
+	}
+	return BoolValue(true, ns), nil
+}
+
+func isBoolBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	params := make([]interface{}, lst.Len())
+	for i := 1; i < lst.Len(); i++ {
+		p, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = p
+	}
+
+	for i := 1; i < lst.Len(); i++ {
+		_, ok := params[i].(bool)
+		if !ok {
+			return BoolValue(false, ns), nil
 		}
 	}
-	return BoolToInt(true), nil
+	return BoolValue(true, ns), nil
 }
 
 // RegisterOperators stに演算子のシンボルを、nsに演算子に対応する拡張関数をそれぞれ登録する。
@@ -774,4 +878,5 @@ func RegisterOperators(ns *Namespace) {
 	ns.RegisterExtension(isStrSymbol, nil, isStrBody)
 	ns.RegisterExtension(isIntSymbol, nil, isIntBody)
 	ns.RegisterExtension(isFloatSymbol, nil, isFloatBody)
+	ns.RegisterExtension(isBoolSymbol, nil, isBoolBody)
 }
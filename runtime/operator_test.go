@@ -29,9 +29,9 @@ var addtests []optest = []optest{
 	{`(+ "abc" 1.0)`, false, true, nil},
 	{`(+ 1 + "abc")`, false, true, nil},
 	{`(+ "" 123)`, false, true, nil},
-	{`(+ true 1)`, false, false, int64(2)},
-	{`(+ 1 false)`, false, false, int64(1)},
-	{`(+ true false)`, false, false, int64(1)},
+	{`(+ true 1)`, false, true, nil},
+	{`(+ 1 false)`, false, true, nil},
+	{`(+ true false)`, false, true, nil},
 	{`(+ true "true")`, false, true, nil},
 	{`(+)`, false, true, nil},
 }
@@ -45,9 +45,9 @@ var subtests []optest = []optest{
 	{`(- 1 2 (- -10.0 -20.0) 3)`, false, true, nil},
 	{`(- 1.0 2 3)`, false, true, nil},
 	{`(- 1 2.1 3.0)`, false, true, nil},
-	{`(- true 1)`, false, false, int64(0)},
-	{`(- 1 false)`, false, false, int64(1)},
-	{`(- true false)`, false, false, int64(1)},
+	{`(- true 1)`, false, true, nil},
+	{`(- 1 false)`, false, true, nil},
+	{`(- true false)`, false, true, nil},
 	{`(- true "true")`, false, true, nil},
 	{`(-)`, false, true, nil},
 }
@@ -61,9 +61,9 @@ var multests []optest = []optest{
 	{`(* 1 2 (* -10.0 -20.0) 3)`, false, true, nil},
 	{`(* 1.0 2 3)`, false, true, nil},
 	{`(* 1 2.1 3.0)`, false, true, nil},
-	{`(* true 1)`, false, false, int64(1)},
-	{`(* 1 false)`, false, false, int64(0)},
-	{`(* true false)`, false, false, int64(0)},
+	{`(* true 1)`, false, true, nil},
+	{`(* 1 false)`, false, true, nil},
+	{`(* true false)`, false, true, nil},
 	{`(* true "true")`, false, true, nil},
 	{`(*)`, false, true, nil},
 }
@@ -81,7 +81,7 @@ var divtests []optest = []optest{
 	{`(/ 1.0 2 3)`, false, true, nil},
 	{`(/ 1 2.1 3.0)`, false, true, nil},
 	{`(/ 1 0.0)`, false, true, nil},
-	{`(/ true 1)`, false, false, int64(1)},
+	{`(/ true 1)`, false, true, nil},
 	{`(/ 1 false)`, false, true, nil},
 	{`(/ true false)`, false, true, nil},
 	{`(/ true "true")`, false, true, nil},
@@ -96,7 +96,7 @@ var remtests []optest = []optest{
 	{`(% 1 2.0)`, false, true, nil},
 	{`(% "1" 2)`, false, true, nil},
 	{`(% 1 "2")`, false, true, nil},
-	{`(% true 1)`, false, false, int64(0)},
+	{`(% true 1)`, false, true, nil},
 	{`(% 1 false)`, false, true, nil},
 	{`(% true false)`, false, true, nil},
 	{`(% true "true")`, false, true, nil},
@@ -105,80 +105,97 @@ var remtests []optest = []optest{
 }
 
 var eqtests []optest = []optest{
-	{`(eq 1 1)`, false, false, int64(1)},
-	{`(eq 2 1)`, false, false, int64(0)},
-	{`(eq 1.0 1.0)`, false, false, int64(1)},
-	{`(eq 2.0 1.0)`, false, false, int64(0)},
-	{`(eq "abc" "abc")`, false, false, int64(1)},
-	{`(eq "abc" "123")`, false, false, int64(0)},
+	{`(eq 1 1)`, false, false, true},
+	{`(eq 2 1)`, false, false, false},
+	{`(eq 1.0 1.0)`, false, false, true},
+	{`(eq 2.0 1.0)`, false, false, false},
+	{`(eq "abc" "abc")`, false, false, true},
+	{`(eq "abc" "123")`, false, false, false},
 	{`(eq 1 1.0)`, false, true, nil},
 	{`(eq 1 "1")`, false, true, nil},
-	{`(eq 1 true)`, false, false, int64(1)},
+	{`(eq 1 true)`, false, true, nil},
+	{`(eq true true)`, false, false, true},
+	{`(eq true false)`, false, false, false},
 	{`(eq 1)`, false, true, false},
 }
 
 var lttests []optest = []optest{
-	{`(< 1 2)`, false, false, int64(1)},
-	{`(< 2 1)`, false, false, int64(0)},
-	{`(< 2 2)`, false, false, int64(0)},
-	{`(< 1.0 2.0)`, false, false, int64(1)},
-	{`(< 2.0 1.0)`, false, false, int64(0)},
-	{`(< 2.0 2.0)`, false, false, int64(0)},
+	{`(< 1 2)`, false, false, true},
+	{`(< 2 1)`, false, false, false},
+	{`(< 2 2)`, false, false, false},
+	{`(< 1.0 2.0)`, false, false, true},
+	{`(< 2.0 1.0)`, false, false, false},
+	{`(< 2.0 2.0)`, false, false, false},
 	{`(< 1 2.0)`, false, true, nil},
 	{`(< 2 1.0)`, false, true, nil},
 	{`(< 2 2.0)`, false, true, nil},
 	{`(< 1.0 2)`, false, true, nil},
 	{`(< 2.0 1)`, false, true, nil},
 	{`(< 2.0 2)`, false, true, nil},
-	{`(< 1 2 3)`, false, true, nil},
+	{`(< 1 2 3)`, false, false, true},
+	{`(< 1 3 2)`, false, false, false},
+	{`(< 1 2 2)`, false, false, false},
+	{`(< 2 1 "a")`, false, true, nil},
+	{`(< 1)`, false, true, nil},
+	{`(< "abc" "abd")`, false, false, true},
+	{`(< "b" "a")`, false, false, false},
+	{`(< "a" "ab" "b")`, false, false, true},
+	{`(< "a10" "a9")`, false, false, true},
+	{`(< "a" 1)`, false, true, nil},
+	{`(< true false)`, false, true, nil},
+	{`(< nan 1.0)`, false, false, false},
+	{`(>= nan nan)`, false, false, false},
 }
 
 var ltetests []optest = []optest{
-	{`(<= 1 2)`, false, false, int64(1)},
-	{`(<= 2 1)`, false, false, int64(0)},
-	{`(<= 2 2)`, false, false, int64(1)},
-	{`(<= 1.0 2.0)`, false, false, int64(1)},
-	{`(<= 2.0 1.0)`, false, false, int64(0)},
-	{`(<= 2.0 2.0)`, false, false, int64(1)},
+	{`(<= 1 2)`, false, false, true},
+	{`(<= 2 1)`, false, false, false},
+	{`(<= 2 2)`, false, false, true},
+	{`(<= 1.0 2.0)`, false, false, true},
+	{`(<= 2.0 1.0)`, false, false, false},
+	{`(<= 2.0 2.0)`, false, false, true},
 	{`(<= 1 2.0)`, false, true, nil},
 	{`(<= 2 1.0)`, false, true, nil},
 	{`(<= 2 2.0)`, false, true, nil},
 	{`(<= 1.0 2)`, false, true, nil},
 	{`(<= 2.0 1)`, false, true, nil},
 	{`(<= 2.0 2)`, false, true, nil},
-	{`(<= 1 2 3)`, false, true, nil},
+	{`(<= 1 2 2)`, false, false, true},
+	{`(<= "a" "a")`, false, false, true},
 }
 
 var gttests []optest = []optest{
-	{`(> 1 2)`, false, false, int64(0)},
-	{`(> 2 1)`, false, false, int64(1)},
-	{`(> 2 2)`, false, false, int64(0)},
-	{`(> 1.0 2.0)`, false, false, int64(0)},
-	{`(> 2.0 1.0)`, false, false, int64(1)},
-	{`(> 2.0 2.0)`, false, false, int64(0)},
+	{`(> 1 2)`, false, false, false},
+	{`(> 2 1)`, false, false, true},
+	{`(> 2 2)`, false, false, false},
+	{`(> 1.0 2.0)`, false, false, false},
+	{`(> 2.0 1.0)`, false, false, true},
+	{`(> 2.0 2.0)`, false, false, false},
 	{`(> 1 2.0)`, false, true, nil},
 	{`(> 2 1.0)`, false, true, nil},
 	{`(> 2 2.0)`, false, true, nil},
 	{`(> 1.0 2)`, false, true, nil},
 	{`(> 2.0 1)`, false, true, nil},
 	{`(> 2.0 2)`, false, true, nil},
-	{`(> 1 2 3)`, false, true, nil},
+	{`(> 3 2 1)`, false, false, true},
+	{`(> "b" "a")`, false, false, true},
 }
 
 var gtetests []optest = []optest{
-	{`(>= 1 2)`, false, false, int64(0)},
-	{`(>= 2 1)`, false, false, int64(1)},
-	{`(>= 2 2)`, false, false, int64(1)},
-	{`(>= 1.0 2.0)`, false, false, int64(0)},
-	{`(>= 2.0 1.0)`, false, false, int64(1)},
-	{`(>= 2.0 2.0)`, false, false, int64(1)},
+	{`(>= 1 2)`, false, false, false},
+	{`(>= 2 1)`, false, false, true},
+	{`(>= 2 2)`, false, false, true},
+	{`(>= 1.0 2.0)`, false, false, false},
+	{`(>= 2.0 1.0)`, false, false, true},
+	{`(>= 2.0 2.0)`, false, false, true},
 	{`(>= 1 2.0)`, false, true, nil},
 	{`(>= 2 1.0)`, false, true, nil},
 	{`(>= 2 2.0)`, false, true, nil},
 	{`(>= 1.0 2)`, false, true, nil},
 	{`(>= 2.0 1)`, false, true, nil},
 	{`(>= 2.0 2)`, false, true, nil},
-	{`(>= 1 2 3)`, false, true, nil},
+	{`(>= 3 3 1)`, false, false, true},
+	{`(>= "a" "b")`, false, false, false},
 }
 
 var strtests = []optest{
@@ -186,8 +203,8 @@ var strtests = []optest{
 	{`(str -10)`, false, false, fmt.Sprint(int64(-10))},
 	{`(str 1.0)`, false, false, fmt.Sprint(float64(1.0))},
 	{`(str 3.1415926535)`, false, false, fmt.Sprint(float64(3.1415926535))},
-	{`(str true)`, false, false, fmt.Sprint(int64(1))},
-	{`(str false)`, false, false, fmt.Sprint(int64(0))},
+	{`(str true)`, false, false, "true"},
+	{`(str false)`, false, false, "false"},
 }
 
 var inttests = []optest{
@@ -208,7 +225,59 @@ var floattests = []optest{
 	{`(float false)`, false, false, float64(int64(0))},
 }
 
+var booltests = []optest{
+	{`(not true)`, false, false, false},
+	{`(not (< 2 1))`, false, false, true},
+	{`(not 1)`, false, true, nil},
+	{`(and true (< 1 2))`, false, false, true},
+	{`(and true false)`, false, false, false},
+	{`(and false 1)`, false, false, false},
+	{`(and true 1)`, false, true, nil},
+	{`(or false (< 1 2))`, false, false, true},
+	{`(or false false)`, false, false, false},
+	{`(or 0 true)`, false, true, nil},
+	{`(if true 1 2)`, false, false, int64(1)},
+	{`(if (eq 1 2) 1 2)`, false, false, int64(2)},
+	{`(if 1 1 2)`, false, true, nil},
+	{`(is-bool true (> 1 2))`, false, false, true},
+	{`(is-bool true 1)`, false, false, false},
+}
+
+var niltests = []optest{
+	{`(is-nil nil)`, false, false, true},
+	{`(is-nil nil 0)`, false, false, false},
+	{`(eq nil nil)`, false, false, true},
+	{`(eq 1 nil)`, false, false, false},
+	{`(eq nil "")`, false, false, false},
+	{`(coalesce nil nil 3 (/ 1 0))`, false, false, int64(3)},
+	{`(coalesce nil nil)`, false, false, nil},
+	{`(default nil "none")`, false, false, "none"},
+	{`(default 0 "none")`, false, false, int64(0)},
+	{`(default nil)`, false, true, nil},
+	{`(str "a" nil)`, false, false, "anil"},
+	{`(+ nil 1)`, false, true, nil},
+	{`(if nil 1 2)`, false, true, nil},
+}
+
+// 互換モードでは真偽値はint64の1と0になる。
+var intbooltests = []optest{
+	{`(+ true 1)`, false, false, int64(2)},
+	{`(eq 1 true)`, false, false, int64(1)},
+	{`(< 1 2)`, false, false, int64(1)},
+	{`(not 1)`, false, false, int64(0)},
+	{`(and true 0)`, false, false, int64(0)},
+	{`(if 2 1 2)`, false, false, int64(1)},
+	{`(str (> 2 1))`, false, false, "1"},
+	{`(is-bool true)`, false, false, int64(0)},
+	{`(format "%t/%t" (> 2 1) false)`, false, false, "true/false"},
+	{`(format "%d" (> 2 1))`, false, false, "1"},
+}
+
 func doOpTests(name string, t *testing.T, tests []optest) {
+	doOpTestsWithConfig(name, t, tests, runtime.Config{})
+}
+
+func doOpTestsWithConfig(name string, t *testing.T, tests []optest, cfg runtime.Config) {
 	for i, tst := range tests {
 		st := parser.NewSymbolTable()
 		lists, err := parser.ParseString(fmt.Sprintf("%v%d", name, i), st, tst.src)
@@ -218,6 +287,7 @@ func doOpTests(name string, t *testing.T, tests []optest) {
 			}
 		} else {
 			ns := runtime.NewRootNamespace(st)
+			*ns.Config() = cfg
 			runtime.MakeDefaultNamespace(ns)
 			result, err := runtime.EvalList(lists[0], ns)
 			if err != nil {
@@ -226,7 +296,9 @@ func doOpTests(name string, t *testing.T, tests []optest) {
 				}
 			} else {
 				success := false
-				if ir, ok := result.(int64); ok {
+				if result == nil {
+					success = tst.expected == nil
+				} else if ir, ok := result.(int64); ok {
 					if ie, ok := tst.expected.(int64); ok && ir == ie {
 						success = true
 					}
@@ -302,3 +374,73 @@ func TestInt(t *testing.T) {
 func TestFloat(t *testing.T) {
 	doOpTests("TestFloat", t, floattests)
 }
+
+func TestBool(t *testing.T) {
+	doOpTests("TestBool", t, booltests)
+}
+
+func TestNil(t *testing.T) {
+	doOpTests("TestNil", t, niltests)
+}
+
+func TestIntBool(t *testing.T) {
+	doOpTestsWithConfig("TestIntBool", t, intbooltests, runtime.Config{IntBool: true})
+}
+
+var formattests = []optest{
+	{`(format "%5.2f|%-4d|%s" 3.14159 42 "x")`, false, false, " 3.14|42  |x"},
+	{`(format "%x %X %08b %o" 255 "hi" 5 8)`, false, false, "ff 6869 00000101 10"},
+	{`(format "%v %v %v %v %t" 1.5 nil (list 1 "a") "s" true)`, false, false, `1.5 nil (1 "a") s true`},
+	{`(format "100%%")`, false, false, "100%"},
+	{`(format "%d" 1.0)`, false, true, nil},
+	{`(format "%s" 1)`, false, true, nil},
+	{`(format "%t" 1)`, false, true, nil},
+	{`(format "%d %d" 1)`, false, true, nil},
+	{`(format "%d" 1 2)`, false, true, nil},
+	{`(format "%y" 1)`, false, true, nil},
+	{`(format "%*d" 1 2)`, false, true, nil},
+	{`(format "%5")`, false, true, nil},
+	{`(fmt-number 1234567)`, false, false, "1,234,567"},
+	{`(fmt-number -1234567.891 2)`, false, false, "-1,234,567.89"},
+	{`(fmt-number 1234.5 2 "de")`, false, false, "1.234,50"},
+	{`(fmt-number 999 2)`, false, false, "999.00"},
+	{`(fmt-number 9007199254740993 2)`, false, false, "9,007,199,254,740,993.00"},
+	{`(fmt-number -9223372036854775807 1 "de")`, false, false, "-9.223.372.036.854.775.807,0"},
+	{`(fmt-number 1234.5)`, false, false, "1,234.5"},
+	{`(fmt-number 1234 0 "xx")`, false, false, "1,234"},
+	{`(fmt-number 1 -1)`, false, true, nil},
+	{`(fmt-number "1")`, false, true, nil},
+}
+
+func TestFormat(t *testing.T) {
+	doOpTests("TestFormat", t, formattests)
+}
+
+var promotiontests = []optest{
+	{`(+ 1 2.5)`, false, false, 3.5},
+	{`(- 1.5 1)`, false, false, 0.5},
+	{`(* 2 1.5 2)`, false, false, 6.0},
+	{`(/ 3 2.0)`, false, false, 1.5},
+	{`(/ 3 2)`, false, false, int64(1)},
+	{`(/ 1 0.0)`, false, true, nil},
+	{`(< 1 1.5)`, false, false, true},
+	{`(>= 2.0 2)`, false, false, true},
+	{`(eq 1 1.0)`, false, false, true},
+	{`(eq 1 1.5)`, false, false, false},
+	{`(+ 1 "2")`, false, true, nil},
+	{`(eq 1 "1")`, false, true, nil},
+}
+
+func TestNumericPromotion(t *testing.T) {
+	doOpTestsWithConfig("TestNumericPromotion", t, promotiontests, runtime.Config{NumericPromotion: true})
+}
+
+var collationtests = []optest{
+	{`(< "a9" "a10")`, false, false, true},
+	{`(> "img12.png" "img2.png" "img1.png")`, false, false, true},
+	{`(<= "x01" "x1")`, false, false, true},
+}
+
+func TestNaturalCollation(t *testing.T) {
+	doOpTestsWithConfig("TestNaturalCollation", t, collationtests, runtime.Config{Collation: runtime.CollationNatural})
+}
@@ -0,0 +1,237 @@
+package runtime
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	reMatchSymbol        = "re-match"
+	reFindSymbol         = "re-find"
+	reFindAllSymbol      = "re-find-all"
+	reFindSubmatchSymbol = "re-find-submatch"
+	reGroupSymbol        = "re-group"
+	reReplaceSymbol      = "re-replace"
+	reSplitSymbol        = "re-split"
+)
+
+// 正規表現に関するエラーコード
+var (
+	ErrorInvalidRegexp        int
+	ErrorUndefinedRegexpGroup int
+)
+
+func init() {
+	ErrorInvalidRegexp = RegisterEvalError("Invalid regular expression %v: %v")
+	ErrorUndefinedRegexpGroup = RegisterEvalError("Undefined group %v in the regular expression %v")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidRegexp, "正規表現 %v が不正です: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorUndefinedRegexpGroup, "グループ %v は正規表現 %v に含まれていません")
+}
+
+// maxCachedRegexps 一つのインタプリタでキャッシュするコンパイル済みの正規表現の最大数
+const maxCachedRegexps = 256
+
+// regexpCache コンパイル済みの正規表現のキャッシュ。ルートの名前空間ごとに持ち、複数のゴルーチンから同時に使用できる。
+type regexpCache struct {
+	mutex   sync.Mutex
+	regexps map[string]*regexp.Regexp
+}
+
+func newRegexpCache() *regexpCache {
+	return &regexpCache{regexps: make(map[string]*regexp.Regexp)}
+}
+
+// compile パターンをコンパイルした正規表現を返す。コンパイル済みの場合はキャッシュしたものを返す。
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if re, ok := c.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// 上限に達した場合はすべて捨ててから登録し直す。
+	if len(c.regexps) >= maxCachedRegexps {
+		c.regexps = make(map[string]*regexp.Regexp)
+	}
+	c.regexps[pattern] = re
+	return re, nil
+}
+
+// evalAsRegexp 名前空間nsでelmを評価し、その結果の文字列をパターンとしてコンパイルした正規表現を返す。
+func evalAsRegexp(elm parser.SyntaxElement, ns *Namespace) (*regexp.Regexp, error) {
+	pattern, err := EvalAsString(elm, ns)
+	if err != nil {
+		return nil, err
+	}
+	re, err := ns.Root().regexps.compile(pattern)
+	if err != nil {
+		return nil, NewEvalError(elm.Position(), ErrorInvalidRegexp, pattern, err)
+	}
+	return re, nil
+}
+
+// evalRegexpArgs (関数名 パターン 文字列 ...)の形式のlstのパターンと文字列を評価する。
+// 引数の数はmin個以上max個以下でなければならない。
+func evalRegexpArgs(lst *parser.List, ns *Namespace, min int, max int) (*regexp.Regexp, string, error) {
+	if lst.Len()-1 < min || lst.Len()-1 > max {
+		return nil, "", NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, max)
+	}
+	re, err := evalAsRegexp(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := EvalAsString(lst.ElementAt(2), ns)
+	if err != nil {
+		return nil, "", err
+	}
+	return re, s, nil
+}
+
+// evalLimit lstのindex番目の要素があれば評価して結果の個数の上限として返す。なければ-1（無制限）を返す。
+func evalLimit(lst *parser.List, index int, ns *Namespace) (int, error) {
+	if lst.Len() <= index {
+		return -1, nil
+	}
+	n, err := EvalAsInt(lst.ElementAt(index), ns)
+	return int(n), err
+}
+
+// reMatchBody (re-match パターン 文字列)
+// 文字列がパターンに一致する部分を含む場合に真を返す。
+func reMatchBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	return BoolValue(re.MatchString(s), ns), nil
+}
+
+// reFindBody (re-find パターン 文字列)
+// パターンに一致する最初の部分文字列を返す。一致しない場合はnilを返す。
+func reFindBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	return s[loc[0]:loc[1]], nil
+}
+
+// reFindAllBody (re-find-all パターン 文字列 [最大数])
+// パターンに一致するすべての部分文字列のリストを返す。
+func reFindAllBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	n, err := evalLimit(lst, 3, ns)
+	if err != nil {
+		return nil, err
+	}
+	return stringsToList(re.FindAllString(s, n)), nil
+}
+
+// reFindSubmatchBody (re-find-submatch パターン 文字列)
+// パターンに一致する最初の部分の、一致した文字列全体と各グループの文字列のリストを返す。
+// 一致しない場合はnilを、一致しなかったグループはnilを要素とする。
+func reFindSubmatchBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, nil
+	}
+	result := make([]interface{}, len(loc)/2)
+	for i := range result {
+		if loc[2*i] >= 0 {
+			result[i] = s[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return result, nil
+}
+
+// reGroupBody (re-group パターン 文字列 グループ)
+// パターンに一致する最初の部分で、名前または番号で指定したグループに一致した文字列を返す。
+// 一致しない場合はnilを返す。
+func reGroupBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 3, 3)
+	if err != nil {
+		return nil, err
+	}
+	g, err := EvalElement(lst.ElementAt(3), ns)
+	if err != nil {
+		return nil, err
+	}
+	index := -1
+	switch v := g.(type) {
+	case string:
+		for i, n := range re.SubexpNames() {
+			if n != "" && n == v {
+				index = i
+				break
+			}
+		}
+	case int64:
+		if v >= 0 && v <= int64(re.NumSubexp()) {
+			index = int(v)
+		}
+	}
+	if index < 0 {
+		return nil, NewEvalError(lst.ElementAt(3).Position(), ErrorUndefinedRegexpGroup, g, re.String())
+	}
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil || loc[2*index] < 0 {
+		return nil, nil
+	}
+	return s[loc[2*index]:loc[2*index+1]], nil
+}
+
+// reReplaceBody (re-replace パターン 文字列 置換文字列)
+// パターンに一致するすべての部分を置換文字列で置き換える。置換文字列の$1や${name}はグループに一致した文字列に展開する。
+func reReplaceBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 3, 3)
+	if err != nil {
+		return nil, err
+	}
+	repl, err := EvalAsString(lst.ElementAt(3), ns)
+	if err != nil {
+		return nil, err
+	}
+	return re.ReplaceAllString(s, repl), nil
+}
+
+// reSplitBody (re-split パターン 文字列 [最大数])
+// パターンに一致する部分で分割した文字列のリストを返す。
+func reSplitBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	re, s, err := evalRegexpArgs(lst, ns, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	n, err := evalLimit(lst, 3, ns)
+	if err != nil {
+		return nil, err
+	}
+	return stringsToList(re.Split(s, n)), nil
+}
+
+// RegisterRegexp 正規表現に関する拡張関数を登録する。
+func RegisterRegexp(ns *Namespace) {
+	ns.RegisterExtension(reMatchSymbol, nil, reMatchBody)
+	ns.RegisterExtension(reFindSymbol, nil, reFindBody)
+	ns.RegisterExtension(reFindAllSymbol, nil, reFindAllBody)
+	ns.RegisterExtension(reFindSubmatchSymbol, nil, reFindSubmatchBody)
+	ns.RegisterExtension(reGroupSymbol, nil, reGroupBody)
+	ns.RegisterExtension(reReplaceSymbol, nil, reReplaceBody)
+	ns.RegisterExtension(reSplitSymbol, nil, reSplitBody)
+}
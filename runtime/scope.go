@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	letSymbol     = "let"
+	letStarSymbol = "let*"
+	defineSymbol  = "define"
+	setBangSymbol = "set!"
+)
+
+// スコープに関するエラーコード
+var (
+	ErrorALetFormRequiresABindingList     int
+	ErrorABindingMustBeAPairOfSymbolValue int
+	ErrorTheSymbolIsAlreadyDefined        int
+)
+
+func init() {
+	ErrorALetFormRequiresABindingList = RegisterEvalError("A let form requires a list of bindings.")
+	ErrorABindingMustBeAPairOfSymbolValue = RegisterEvalError("A binding must be a pair of a symbol and a value.")
+	ErrorTheSymbolIsAlreadyDefined = RegisterEvalError("The symbol %v is already defined.")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorALetFormRequiresABindingList, "letには束縛のリストが必要です。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorABindingMustBeAPairOfSymbolValue, "束縛はシンボルと値の組でなければなりません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheSymbolIsAlreadyDefined, "シンボル %v は既に定義されています。")
+}
+
+// evalLet (let ((シンボル 値) ...) 式 ...)の形式のリストを評価する。
+// 束縛と式は新しい子の名前空間で評価し、最後の式の評価結果を返す。
+// sequentialがfalseの場合、束縛する値は元の名前空間で評価する（let）。
+// trueの場合は子の名前空間で順に評価し、前の束縛を参照できる（let*）。
+func evalLet(lst *parser.List, ns *Namespace, sequential bool) (interface{}, error) {
+	if lst.Len() < 3 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 2)
+	}
+	bindings := lst.ElementAt(1)
+	if !bindings.IsList() {
+		return nil, NewEvalError(bindings.Position(), ErrorALetFormRequiresABindingList)
+	}
+	bl := bindings.(*parser.List)
+	lns := NewNamespace(ns)
+	for i := 0; i < bl.Len(); i++ {
+		b := bl.ElementAt(i)
+		if !b.IsList() || b.(*parser.List).Len() != 2 {
+			return nil, NewEvalError(b.Position(), ErrorABindingMustBeAPairOfSymbolValue)
+		}
+		sid, ok := b.(*parser.List).SymbolAt(0)
+		if !ok {
+			return nil, NewEvalError(b.ElementAt(0).Position(), ErrorYouCannotBindAValueToAnythingOtherThanASymbol)
+		}
+		ens := ns
+		if sequential {
+			ens = lns
+		}
+		v, err := EvalElement(b.ElementAt(1), ens)
+		if err != nil {
+			return nil, err
+		}
+		if err := lns.Set(sid, v); err != nil {
+			return nil, withPosition(err, b.Position())
+		}
+	}
+	return evalSequence(lst, 2, lns)
+}
+
+func letBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalLet(lst, ns, false)
+}
+
+func letStarBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return evalLet(lst, ns, true)
+}
+
+// defineBody 現在の名前空間に新しい束縛を作る。既に現在の名前空間で定義されている場合はエラーにする。
+func defineBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return bindBody(lst, ns, func(n *Namespace, id parser.SymbolID, v interface{}) error {
+		if n.IsDefinedLocally(id) {
+			return NewEvalError(parser.Position{}, ErrorTheSymbolIsAlreadyDefined, symbolNameOrID(n, id))
+		}
+		return n.Set(id, v)
+	})
+}
+
+// setBangBody 親の名前空間をたどり、最も近い既存の束縛の値を更新する。束縛が存在しない場合はエラーにする。
+func setBangBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return bindBody(lst, ns, func(n *Namespace, id parser.SymbolID, v interface{}) error {
+		d := n.DefinedIn(id)
+		if d == nil {
+			return NewEvalError(parser.Position{}, ErrorUndefinedSymbol, symbolNameOrID(n, id))
+		}
+		return d.Set(id, v)
+	})
+}
+
+// RegisterScope スコープに関する拡張関数を登録する。
+func RegisterScope(ns *Namespace) {
+	ns.RegisterExtension(letSymbol, nil, letBody)
+	ns.RegisterExtension(letStarSymbol, nil, letStarBody)
+	ns.RegisterExtension(defineSymbol, nil, defineBody)
+	ns.RegisterExtension(setBangSymbol, nil, setBangBody)
+}
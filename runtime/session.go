@@ -0,0 +1,248 @@
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	saveSessionSymbol = "save-session"
+	loadSessionSymbol = "load-session"
+)
+
+// セッションの保存と読み込みに関するエラーコード
+var (
+	ErrorCannotAccessTheSessionFile int
+	ErrorInvalidSessionEntry        int
+)
+
+func init() {
+	ErrorCannotAccessTheSessionFile = RegisterEvalError("Cannot access the session file: %v")
+	ErrorInvalidSessionEntry = RegisterEvalError("Invalid session entry")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorCannotAccessTheSessionFile, "セッションファイルにアクセスできません: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidSessionEntry, "セッションのエントリが不正です")
+}
+
+var (
+	defaultValuesOnce sync.Once
+	defaultValues     map[string]interface{} // MakeDefaultNamespaceで登録される関数以外の値
+)
+
+// isDefaultValue nameにvalueが束縛されているのがMakeDefaultNamespaceで登録された状態と同じならtrueを返す。
+func isDefaultValue(name string, value interface{}) bool {
+	defaultValuesOnce.Do(func() {
+		st := parser.NewSymbolTable()
+		ns := NewRootNamespace(st)
+		MakeDefaultNamespace(ns)
+		defaultValues = make(map[string]interface{})
+		ns.Range(func(id parser.SymbolID, v interface{}) bool {
+			if _, ok := v.(*Function); !ok {
+				n, _ := st.GetSymbolName(id)
+				defaultValues[n] = v
+			}
+			return true
+		})
+	})
+	v, ok := defaultValues[name]
+	return ok && valuesEqual(v, value)
+}
+
+// valueSource 評価するとvになるソースコードを返す。名前のないネイティブ関数など、ソースコードで表せない値の場合はエラーを返す。
+func valueSource(v interface{}, ns *Namespace) (string, error) {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return parser.FormatFloat(v), nil
+	case string:
+		return parser.QuoteString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil // trueまたはfalseのシンボルとして書き出す。
+	case time.Duration:
+		return v.String(), nil // 1h30m0sのような時間の長さのリテラルとして書き出す。
+	case nil:
+		return nilSymbol, nil
+	case []interface{}:
+		var b bytes.Buffer
+		b.WriteString("(" + listSymbol)
+		for _, e := range v {
+			s, err := valueSource(e, ns)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(" " + s)
+		}
+		b.WriteString(")")
+		return b.String(), nil
+	case *Function:
+		if v.native == nil {
+			return FunctionSource(v, ns)
+		}
+		if v.name != "" {
+			return v.name, nil
+		}
+	}
+	return "", NewEvalError(parser.Position{}, ErrorInvalidValueType, fmt.Sprintf("%T", v))
+}
+
+// SaveSession nsのルートの名前空間に束縛されたユーザーの値を、setまたはconstの式の並びとしてwに書き出し、書き出した束縛の数を返す。
+// 数値、文字列、真偽値、時間の長さ、nil、リスト、ユーザー定義関数を書き出す。ネイティブ関数は登録時と異なるシンボルに束縛されている場合のみ書き出す。
+func SaveSession(ns *Namespace, w io.Writer) (int, error) {
+	root := ns.Root()
+	bw := bufio.NewWriter(w)
+	count := 0
+	var err error
+	root.Range(func(id parser.SymbolID, value interface{}) bool {
+		var name, src string
+		name, err = root.GetSymbolName(id)
+		if err != nil {
+			return false
+		}
+		if f, ok := value.(*Function); ok && f.native != nil && f.name == name {
+			return true
+		}
+		src, err = valueSource(value, root)
+		if err != nil {
+			return false
+		}
+		if isDefaultValue(name, value) {
+			return true
+		}
+		form := setSymbol
+		if root.readonly[id] {
+			form = constSymbol
+		}
+		if _, err = bw.WriteString("(" + form + " " + name + " " + src + ")\n"); err != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// sessionValue セッションファイルの値の式eから値を作る。式を評価せずに値を作れるリテラル、シンボル、(list ...)、
+// (func ...)、(lambda ...)だけを受け付け、関数の呼び出しなどそれ以外の式はエラーにする。
+func sessionValue(e parser.SyntaxElement, ns *Namespace) (interface{}, error) {
+	if !e.IsList() {
+		return EvalElement(e, ns)
+	}
+	lst := e.(*parser.List)
+	sid, ok := lst.SymbolAt(0)
+	if !ok {
+		return nil, NewEvalError(e.Position(), ErrorInvalidSessionEntry)
+	}
+	switch sid {
+	case ns.GetSymbolID(listSymbol):
+		result := make([]interface{}, lst.Len()-1)
+		for i := 1; i < lst.Len(); i++ {
+			v, err := sessionValue(lst.ElementAt(i), ns)
+			if err != nil {
+				return nil, err
+			}
+			result[i-1] = v
+		}
+		return result, nil
+	case ns.GetSymbolID(funcSymbol):
+		return funcBody(nil, lst, ns)
+	case ns.GetSymbolID(lambdaSymbol):
+		return lambdaBody(nil, lst, ns)
+	}
+	return nil, NewEvalError(e.Position(), ErrorInvalidSessionEntry)
+}
+
+// LoadSession SaveSessionで書き出したsetまたはconstの式の並びをrから読み込んでnsのルートの名前空間に束縛し、読み込んだ束縛の数を返す。
+// setとconst以外の式や、値に関数の呼び出しなどを含む式がある場合はエラーにする。読み込みの際にコードは実行しない。
+func LoadSession(ns *Namespace, filename string, r io.Reader) (int, error) {
+	root := ns.Root()
+	lists, err := parser.Parse(filename, root.symtbl, r)
+	if err != nil {
+		return 0, err
+	}
+	setid := root.GetSymbolID(setSymbol)
+	constid := root.GetSymbolID(constSymbol)
+	for _, l := range lists {
+		if sid, ok := l.SymbolAt(0); !ok || (sid != setid && sid != constid) || l.Len() != 3 {
+			return 0, NewEvalError(l.Position(), ErrorInvalidSessionEntry)
+		}
+		if _, ok := l.SymbolAt(1); !ok {
+			return 0, NewEvalError(l.ElementAt(1).Position(), ErrorInvalidSessionEntry)
+		}
+	}
+	for i, l := range lists {
+		v, err := sessionValue(l.ElementAt(2), root)
+		if err != nil {
+			return i, err
+		}
+		bind := (*Namespace).Set
+		if sid, _ := l.SymbolAt(0); sid == constid {
+			bind = (*Namespace).SetConst
+		}
+		sid, _ := l.SymbolAt(1)
+		if err := bind(root, sid, v); err != nil {
+			return i, withPosition(err, l.ElementAt(1).Position())
+		}
+	}
+	return len(lists), nil
+}
+
+func saveSessionBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	path, err := EvalAsString(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorCannotAccessTheSessionFile, err)
+	}
+	n, err := SaveSession(ns, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorCannotAccessTheSessionFile, err)
+	}
+	return int64(n), nil
+}
+
+func loadSessionBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	path, err := EvalAsString(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorCannotAccessTheSessionFile, err)
+	}
+	defer f.Close()
+	n, err := LoadSession(ns, path, f)
+	if err != nil {
+		return nil, err
+	}
+	return int64(n), nil
+}
+
+// RegisterSession セッションの保存と読み込みに関する拡張関数を登録する。
+// ファイルシステムにアクセスするため、MakeDefaultNamespaceでは登録しない。
+func RegisterSession(ns *Namespace) {
+	ns.RegisterExtension(saveSessionSymbol, nil, saveSessionBody)
+	ns.RegisterExtension(loadSessionSymbol, nil, loadSessionBody)
+}
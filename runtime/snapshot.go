@@ -0,0 +1,211 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+// スナップショットの値の型
+const (
+	SnapshotInt      = "int"
+	SnapshotFloat    = "float"
+	SnapshotString   = "string"
+	SnapshotBool     = "bool"
+	SnapshotNil      = "nil"
+	SnapshotDuration = "duration" // 時間の長さ。値は1h30m0sの形式
+	SnapshotList     = "list"     // リスト。値は(list ...)の形式のソースコード
+	SnapshotFunc     = "func"     // ユーザー定義関数。値は関数定義のソースコード
+	SnapshotNative   = "native"   // ネイティブ関数。値は関数を登録したシンボル名
+)
+
+// スナップショットに関するエラーコード
+var (
+	ErrorInvalidSnapshotEntry int
+	ErrorCannotSaveAClosure   int
+)
+
+func init() {
+	ErrorInvalidSnapshotEntry = RegisterEvalError("Invalid snapshot entry %v: %v")
+	ErrorCannotSaveAClosure = RegisterEvalError("A lambda that captures local variables cannot be saved")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidSnapshotEntry, "スナップショットのエントリ %v が不正です: %v")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorCannotSaveAClosure, "ローカル変数を保持するlambdaの関数は保存できません")
+}
+
+// SnapshotEntry 名前空間の一つの束縛を文字列だけで表したもの
+type SnapshotEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Const bool   `json:"const,omitempty"` // constで束縛されている場合はtrue
+}
+
+// Snapshot 名前空間の束縛を直列化できる形式で保存したもの
+type Snapshot struct {
+	Entries []SnapshotEntry `json:"entries"`
+}
+
+// FunctionSource ユーザー定義関数fの定義をfuncのソースコードの形式で返す。
+// ルート以外の名前空間で定義したlambdaの関数は、ソースコードにすると本体から参照する変数が変わってしまうのでエラーを返す。
+func FunctionSource(f *Function, ns *Namespace) (string, error) {
+	if f.body == nil {
+		return "", NewEvalError(parser.Position{}, ErrorInvalidOperation)
+	}
+	if f.closure != nil && f.closure != ns.Root() {
+		return "", NewEvalError(parser.Position{}, ErrorCannotSaveAClosure)
+	}
+	var b bytes.Buffer
+	b.WriteString("(")
+	b.WriteString(funcSymbol)
+	b.WriteString(" (")
+	for i, p := range f.params {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		n, err := ns.GetSymbolName(p)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(n)
+	}
+	b.WriteString(") ")
+	body, err := parser.Format(f.body, ns.Root().symtbl)
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(body)
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// Snapshot ns自身に束縛されている値をスナップショットとして保存する。
+func (ns *Namespace) Snapshot() (*Snapshot, error) {
+	s := &Snapshot{make([]SnapshotEntry, 0, ns.Len())}
+	var err error
+	ns.Range(func(id parser.SymbolID, value interface{}) bool {
+		var name string
+		name, err = ns.GetSymbolName(id)
+		if err != nil {
+			return false
+		}
+		e := SnapshotEntry{Name: name, Const: ns.readonly[id]}
+		switch v := value.(type) {
+		case int64:
+			e.Type, e.Value = SnapshotInt, strconv.FormatInt(v, 10)
+		case float64:
+			e.Type, e.Value = SnapshotFloat, strconv.FormatFloat(v, 'g', -1, 64)
+		case string:
+			e.Type, e.Value = SnapshotString, v
+		case bool:
+			e.Type, e.Value = SnapshotBool, strconv.FormatBool(v)
+		case time.Duration:
+			e.Type, e.Value = SnapshotDuration, v.String()
+		case nil:
+			e.Type = SnapshotNil
+		case []interface{}:
+			e.Type = SnapshotList
+			e.Value, err = valueSource(v, ns)
+			if err != nil {
+				return false
+			}
+		case *Function:
+			if v.native != nil {
+				if v.name == "" {
+					err = NewEvalError(parser.Position{}, ErrorInvalidValueType, fmt.Sprintf("%T", value))
+					return false
+				}
+				e.Type, e.Value = SnapshotNative, v.name
+			} else {
+				e.Type = SnapshotFunc
+				e.Value, err = FunctionSource(v, ns)
+				if err != nil {
+					return false
+				}
+			}
+		default:
+			err = NewEvalError(parser.Position{}, ErrorInvalidValueType, fmt.Sprintf("%T", value))
+			return false
+		}
+		s.Entries = append(s.Entries, e)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// restoreValue スナップショットのエントリeから値を復元する。
+func (ns *Namespace) restoreValue(e SnapshotEntry) (interface{}, error) {
+	switch e.Type {
+	case SnapshotInt:
+		return strconv.ParseInt(e.Value, 10, 64)
+	case SnapshotFloat:
+		return strconv.ParseFloat(e.Value, 64)
+	case SnapshotString:
+		return e.Value, nil
+	case SnapshotBool:
+		return strconv.ParseBool(e.Value)
+	case SnapshotNil:
+		return nil, nil
+	case SnapshotDuration:
+		return time.ParseDuration(e.Value)
+	case SnapshotList:
+		lists, err := parser.ParseString(e.Name, ns.Root().symtbl, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(lists) != 1 || lists[0].Len() == 0 {
+			return nil, NewEvalError(parser.Position{}, ErrorInvalidSnapshotEntry, e.Name, e.Value)
+		}
+		return listBody(nil, lists[0], ns.Root())
+	case SnapshotFunc:
+		lists, err := parser.ParseString(e.Name, ns.Root().symtbl, e.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(lists) != 1 || lists[0].Len() == 0 {
+			return nil, NewEvalError(parser.Position{}, ErrorInvalidSnapshotEntry, e.Name, e.Value)
+		}
+		return funcBody(nil, lists[0], ns)
+	case SnapshotNative:
+		// ネイティブ関数は登録時のシンボル名で探し直す。
+		sid, ok := ns.Root().symtbl.Lookup(e.Value)
+		if ok {
+			if f, ok := ns.Root().Get(sid); ok {
+				if nf, ok := f.(*Function); ok && nf.native != nil && nf.name == e.Value {
+					return nf, nil
+				}
+			}
+		}
+	}
+	return nil, NewEvalError(parser.Position{}, ErrorInvalidSnapshotEntry, e.Name, e.Value)
+}
+
+// Restore ns自身の束縛をスナップショットsの内容で置き換える。
+// エラーが発生した場合、nsは変更されない。
+func (ns *Namespace) Restore(s *Snapshot) error {
+	if ns.frozen {
+		return NewEvalError(parser.Position{}, ErrorTheNamespaceIsReadOnly)
+	}
+	bindings := make(map[parser.SymbolID]interface{}, len(s.Entries))
+	readonly := make(map[parser.SymbolID]bool)
+	for _, e := range s.Entries {
+		v, err := ns.restoreValue(e)
+		if err != nil {
+			return err
+		}
+		id := ns.GetSymbolID(e.Name)
+		bindings[id] = v
+		if e.Const {
+			readonly[id] = true
+		}
+	}
+	ns.bindings = bindings
+	ns.readonly = readonly
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 const (
 	setSymbol   = "set"
+	constSymbol = "const"
 	ifSymbol    = "if"
 	whileSymbol = "while"
 	printSymbol = "print"
@@ -32,9 +33,17 @@ func init() {
 	ErrorAFunctionDefinitionRequiresAnArgumentList = RegisterEvalError("A function definition requires an argument list.")
 	ErrorAFunctionDefinitionRequiresAFunctionBodyDefinition = RegisterEvalError("A function definition requires a function body definition.")
 	ErrorTheArgumentListMustConsistOfSymbolsOnly = RegisterEvalError("The argument list must consist of symbols only.")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorYouCannotBindAValueToAnythingOtherThanASymbol, "シンボル以外に値を束縛することはできません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorYouCannotBindMoreThanOneValueToASymbol, "1つのシンボルに複数の値を束縛することはできません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorYouMustSpecifyTheValueToBind, "束縛する値を指定しなければなりません。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorAFunctionDefinitionRequiresAnArgumentList, "関数の定義には引数リストが必要です。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorAFunctionDefinitionRequiresAFunctionBodyDefinition, "関数の定義には関数本体の定義が必要です。")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorTheArgumentListMustConsistOfSymbolsOnly, "引数リストはシンボルのみで構成しなければなりません。")
 }
 
-func setBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+// bindBody (set シンボル 値)、(const シンボル 値)の形式のリストを評価し、値をシンボルに束縛する。
+func bindBody(lst *parser.List, ns *Namespace, bind func(*Namespace, parser.SymbolID, interface{}) error) (interface{}, error) {
 	sid, ok := lst.SymbolAt(1)
 	if !ok {
 		return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorYouCannotBindAValueToAnythingOtherThanASymbol)
@@ -48,10 +57,21 @@ func setBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error
 	if err != nil {
 		return nil, err
 	}
-	ns.Set(sid, v)
+	if err := bind(ns, sid, v); err != nil {
+		return nil, withPosition(err, lst.ElementAt(1).Position())
+	}
 	return v, nil
 }
 
+func setBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return bindBody(lst, ns, (*Namespace).Set)
+}
+
+// constBody 値をシンボルに読み取り専用で束縛する。
+func constBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return bindBody(lst, ns, (*Namespace).SetConst)
+}
+
 func ifBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
 	if lst.Len() != 4 {
 		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 4-1)
@@ -60,13 +80,14 @@ func ifBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error)
 	if err != nil {
 		return nil, err
 	}
-	if cond, ok := p.(int64); ok {
-		if cond != 0 {
-			return EvalElement(lst.ElementAt(2), ns)
-		}
-		return EvalElement(lst.ElementAt(3), ns)
+	cond, err := isTrue(p, lst.ElementAt(1).Position(), ns)
+	if err != nil {
+		return nil, err
+	}
+	if cond {
+		return EvalElement(lst.ElementAt(2), ns)
 	}
-	return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorOperantsMustBeOfIntegerType, p)
+	return EvalElement(lst.ElementAt(3), ns)
 }
 
 func whileBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -75,19 +96,22 @@ func whileBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, err
 	}
 	condelm := lst.ElementAt(1)
 	bodyelm := lst.ElementAt(2)
-	cond, err := EvalAsInt(condelm, ns)
-	count := int64(0)
-	for err == nil && cond != 0 {
-		count++
+	cond, err := EvalAsBool(condelm, ns)
+	var ls loopState
+	for err == nil && cond {
 		_, err = EvalElement(bodyelm, ns)
-		if err == nil { // bodyelmを評価してエラーがなければ再度、ループの条件を確認する。
-			cond, err = EvalAsInt(condelm, ns)
+		exit, lerr := ls.handle(err)
+		if lerr != nil || exit {
+			err = lerr
+			break
 		}
+		// bodyelmを評価してエラーがなければ再度、ループの条件を確認する。
+		cond, err = EvalAsBool(condelm, ns)
 	}
 	if err != nil { // エラーで抜けた場合はEvalError
 		return nil, err
 	}
-	return count, nil // bodyelmを評価した回数を返す。
+	return ls.result(), nil // bodyelmを評価した回数（break、continueで値が指定された場合はその値）を返す。
 }
 
 // printBody fmt.Printlnを呼び出して結果をそのまま返す。
@@ -141,12 +165,13 @@ func funcBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, erro
 		}
 		args[i] = s
 	}
-	return &Function{args, body.(*parser.List), nil, nil}, nil
+	return &Function{args, body.(*parser.List), nil, nil, "", nil}, nil
 }
 
 // RegisterStmt 文に関する拡張関数を登録する。
 func RegisterStmt(ns *Namespace) {
 	ns.RegisterExtension(setSymbol, nil, setBody)
+	ns.RegisterExtension(constSymbol, nil, constBody)
 	ns.RegisterExtension(ifSymbol, nil, ifBody)
 	ns.RegisterExtension(printSymbol, nil, printBody)
 	ns.RegisterExtension(whileSymbol, nil, whileBody)
@@ -0,0 +1,660 @@
+package runtime_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/healthy-tiger/scalc/parser"
+	"github.com/healthy-tiger/scalc/runtime"
+)
+
+// doStmtTests srcのすべてのリストを順に評価し、最後のリストの評価結果を期待値と比較する。
+func doStmtTests(name string, t *testing.T, tests []optest) {
+	for i, tst := range tests {
+		st := parser.NewSymbolTable()
+		lists, err := parser.ParseString(fmt.Sprintf("%v%d", name, i), st, tst.src)
+		if err != nil {
+			if !tst.parseError {
+				t.Errorf("[%d]Parse error: %v\n", i, err)
+			}
+			continue
+		}
+		ns := runtime.NewRootNamespace(st)
+		runtime.MakeDefaultNamespace(ns)
+		var result interface{}
+		for _, l := range lists {
+			result, err = runtime.EvalList(l, ns)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if !tst.evalError {
+				t.Errorf("[%d]Eval error: %v\n", i, err)
+			}
+		} else if tst.evalError {
+			t.Errorf("[%d]No eval error, the result was %v.", i, result)
+		} else if !reflect.DeepEqual(result, tst.expected) {
+			t.Errorf("[%d]The expected value was %v, but the result was %v.", i, tst.expected, result)
+		}
+	}
+}
+
+var consttests = []optest{
+	{`(const a 1) (begin a)`, false, false, int64(1)},
+	{`(const a 1) (set a 2)`, false, true, nil},
+	{`(const a 1) (const a 2)`, false, true, nil},
+	{`(const f (func (x) (* x 2))) (f 3)`, false, false, int64(6)},
+	{`(const a 1) (set f (func (a) (* a 2))) (f 2)`, false, false, int64(4)},
+	{`(const a 1) (let ((a 2)) a)`, false, false, int64(2)},
+	{`(const a 1) (let ((a 2)) (set! a 3)) (begin a)`, false, false, int64(1)},
+	{`(set a 1) (const a 2) (begin a)`, false, false, int64(2)},
+	{`(set max 1) (begin max)`, false, false, int64(1)},
+	{`(const 1 1)`, false, true, nil},
+}
+
+func TestConst(t *testing.T) {
+	doStmtTests("TestConst", t, consttests)
+}
+
+func TestLockBuiltins(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	ns.LockBuiltins()
+	// 組み込みの値は、関数の中やletのローカル変数、引数としても束縛し直すことはできない。
+	for i, src := range []string{
+		`(set max 1)`,
+		`(set Pi 3.0)`,
+		`(const + 1)`,
+		`(set true 0)`,
+		`(set f (func () (begin (set max 1) max))) (f)`,
+		`(set f (func (max) (+ max 1))) (f 2)`,
+		`(let ((min 3)) min)`,
+		`(let ((a 1)) (set str "b"))`,
+	} {
+		if _, err := evalSource("TestLockBuiltins", st, ns, src); err == nil {
+			t.Errorf("[%d]A builtin was reassigned", i)
+		} else if ee, ok := err.(*runtime.EvalError); !ok || ee.ID != runtime.ErrorTheSymbolIsReadOnly {
+			t.Errorf("[%d]Unexpected error: %v", i, err)
+		}
+	}
+	if !ns.IsReadOnly(st.GetSymbolID("max")) {
+		t.Error("max is not read-only")
+	}
+	if r, err := evalSource("TestLockBuiltins", st, ns, `(max 1.0 2.0)`); err != nil || r != 2.0 {
+		t.Errorf("Unexpected result %v %v", r, err)
+	}
+
+	// 基底の名前空間の組み込みの値は、基底の名前空間と子の名前空間のどちらでロックしても読み取り専用になる。
+	unlocked := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(unlocked)
+	unlocked.Freeze()
+	for i, tst := range []struct {
+		base   *runtime.Namespace
+		lock   bool
+		locked bool
+	}{
+		{ns, false, true},
+		{unlocked, true, true},
+		{unlocked, false, false},
+	} {
+		child := runtime.NewRootNamespaceWithBase(tst.base)
+		if tst.lock {
+			child.LockBuiltins()
+		}
+		for _, src := range []string{`(set + 5)`, `(set f (func () (begin (set max 1) max))) (f)`} {
+			_, err := evalSource("TestLockBuiltins", st, child, src)
+			if tst.locked && err == nil {
+				t.Errorf("[%d]A builtin of the base namespace was reassigned: %s", i, src)
+			} else if !tst.locked && err != nil {
+				t.Errorf("[%d]Unexpected error: %v", i, err)
+			}
+		}
+	}
+}
+
+// evalSource srcの式を順に評価し、最後の評価結果を返す。
+func evalSource(name string, st *parser.SymbolTable, ns *runtime.Namespace, src string) (interface{}, error) {
+	lists, err := parser.ParseString(name, st, src)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	for _, l := range lists {
+		if result, err = runtime.EvalList(l, ns); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+var scopetests = []optest{
+	{`(let ((a 1) (b 2)) (+ a b))`, false, false, int64(3)},
+	{`(set a 1) (let ((a 2) (b a)) b)`, false, false, int64(1)},
+	{`(set a 1) (let* ((a 2) (b a)) b)`, false, false, int64(2)},
+	{`(let ((a 1)) (set a 2) (+ a 1))`, false, false, int64(3)},
+	{`(let ((a 1)) (set a 2)) (begin a)`, false, true, nil},
+	{`(set a 1) (let ((b 2)) (set! a b)) (begin a)`, false, false, int64(2)},
+	{`(set a 1) (let ((a 5)) (set! a 2)) (begin a)`, false, false, int64(1)},
+	{`(set n 0) (set inc (func (x) (set! n (+ n x)))) (inc 2) (inc 3) (begin n)`, false, false, int64(5)},
+	{`(set! a 1)`, false, true, nil},
+	{`(define a 1) (begin a)`, false, false, int64(1)},
+	{`(define a 1) (define a 2)`, false, true, nil},
+	{`(define a 1) (let ((b 0)) (define a 2) (begin a))`, false, false, int64(2)},
+	{`(let (a 1) a)`, false, true, nil},
+	{`(let a a)`, false, true, nil},
+	{`(let ((a 1)))`, false, true, nil},
+}
+
+func TestScope(t *testing.T) {
+	doStmtTests("TestScope", t, scopetests)
+}
+
+var condtests = []optest{
+	{`(set x 5) (cond ((< x 0) "neg") ((eq x 0) "zero") (else "pos"))`, false, false, "pos"},
+	{`(set x 0) (cond ((< x 0) "neg") ((eq x 0) "zero") (else "pos"))`, false, false, "zero"},
+	{`(cond ((< 1 0) 1) ((< 0 1)))`, false, false, true},
+	{`(cond ((< 1 0) 1))`, false, false, false},
+	{`(cond (else 1) ((< 1 0) 2))`, false, true, nil},
+	{`(cond 1)`, false, true, nil},
+	{`(cond ("a" 1))`, false, true, nil},
+	{`(case 2 (1 "one") ((2 3) "two or three") (else "other"))`, false, false, "two or three"},
+	{`(case "b" ("a" 1) (("b" "c") 2) (else 3))`, false, false, int64(2)},
+	{`(case 1.0 (1 "int") (1.0 "float"))`, false, false, "float"},
+	{`(case 9 (1 "one") (else "other"))`, false, false, "other"},
+	{`(switch Pi (Pi "pi") (else "other"))`, false, false, "pi"},
+	{`(when (< 1 2) (set a 1) (+ a 1))`, false, false, int64(2)},
+	{`(when (> 1 2) (set a 1) (+ a 1))`, false, false, false},
+	{`(unless (> 1 2) 10)`, false, false, int64(10)},
+	{`(unless (< 1 2) 10)`, false, false, false},
+}
+
+func TestCond(t *testing.T) {
+	doStmtTests("TestCond", t, condtests)
+}
+
+var looptests = []optest{
+	{`(set s 0) (for (i 1 10) (set s (+ s i))) (begin s)`, false, false, int64(55)},
+	{`(for (i 1 10) i)`, false, false, int64(10)},
+	{`(set s 0) (for (i 10 1 -3) (set s (+ s i))) (begin s)`, false, false, int64(10 + 7 + 4 + 1)},
+	{`(for (i 1 0) i)`, false, false, int64(0)},
+	{`(for (i 1 10 0) i)`, false, true, nil},
+	{`(for (i 1 10) (when (eq i 4) (break (* i 100))))`, false, false, int64(400)},
+	{`(for (i 1 10) (when (eq i 4) (break)))`, false, false, int64(4)},
+	{`(set s 0) (for (i 1 10) (when (eq (% i 2) 0) (continue)) (set s (+ s i))) (begin s)`, false, false, int64(25)},
+	{`(set s "") (foreach (c "日本語") (set s (str c "-" s))) (begin s)`, false, false, "語-本-日-"},
+	{`(foreach (c "abc") (when (eq c "b") (break c)))`, false, false, "b"},
+	{`(foreach (c 1) c)`, false, true, nil},
+	{`(set i 0) (while (< i 10) (begin (set i (+ i 1)) (when (eq i 3) (break "three"))))`, false, false, "three"},
+	{`(set i 0) (while (< i 10) (set i (+ i 1)))`, false, false, int64(10)},
+	{`(break 1)`, false, true, nil},
+	{`(set f (func () (break 1))) (for (i 1 3) (f))`, false, true, nil},
+	{`(for (i 1 3) (for (j 1 3) (break j)) (continue i))`, false, false, int64(3)},
+	{`(while true (break nil))`, false, false, nil},
+	{`(for (i 1 3) (when (eq i 2) (break nil)))`, false, false, nil},
+	{`(foreach (x (list 1 2)) (continue nil))`, false, false, nil},
+	{`(for (i 9223372036854775806 9223372036854775807) i)`, false, false, int64(2)},
+	{`(for (i (- -9223372036854775807 1) -9223372036854775807 2) i)`, false, false, int64(1)},
+	{`(for (i -9223372036854775807 (- -9223372036854775807 1) -1) i)`, false, false, int64(2)},
+	{`(for (i 0 9223372036854775807 4611686018427387904) i)`, false, false, int64(2)},
+}
+
+func TestLoop(t *testing.T) {
+	doStmtTests("TestLoop", t, looptests)
+}
+
+var returntests = []optest{
+	{`(set f (func (x) (begin (when (< x 0) (return "neg")) "pos"))) (f -1)`, false, false, "neg"},
+	{`(set f (func (x) (begin (when (< x 0) (return "neg")) "pos"))) (f 1)`, false, false, "pos"},
+	{`(set f (func (n) (for (i 1 n) (when (> (* i i) n) (return i))))) (f 10)`, false, false, int64(4)},
+	{`(set g (func () (return 1))) (set f (func () (+ (g) 1))) (f)`, false, false, int64(2)},
+	{`(return 1)`, false, true, nil},
+	{`(for (i 1 3) (return i))`, false, true, nil},
+	{`(set f (func () (return))) (f)`, false, true, nil},
+}
+
+func TestReturn(t *testing.T) {
+	doStmtTests("TestReturn", t, returntests)
+}
+
+func TestControlFlowOutsideOfLoop(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	for i, tst := range []struct {
+		src    string
+		id     int
+		column int
+	}{
+		{`(break)`, runtime.ErrorCannotBeUsedOutsideOfALoop, 1},
+		{`(begin 1 (continue 2))`, runtime.ErrorCannotBeUsedOutsideOfALoop, 10},
+		{`(if true (return 1) 0)`, runtime.ErrorCannotBeUsedOutsideOfAFunc, 10},
+	} {
+		lists, err := parser.ParseString("TestControlFlowOutsideOfLoop", st, tst.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = runtime.EvalList(lists[0], ns)
+		ee, ok := err.(*runtime.EvalError)
+		if !ok || ee.ID != tst.id || ee.ErrorLocation.Column != tst.column {
+			t.Errorf("[%d]Unexpected error: %#v", i, err)
+		}
+	}
+}
+
+var functionaltests = []optest{
+	{`(list 1 "a" (list 2.0 nil))`, false, false, []interface{}{int64(1), "a", []interface{}{2.0, nil}}},
+	{`(str (list 1 "a" (list true nil)))`, false, false, `(1 "a" (true nil))`},
+	{`(eq (list 1 (list 2)) (list 1 (list 2)))`, false, false, true},
+	{`(eq (list 1 2) (list 1))`, false, false, false},
+	{`(apply + 1 2 (list 3 4))`, false, false, int64(10)},
+	{`(apply (func (a b) (- a b)) (list 5 3))`, false, false, int64(2)},
+	{`(apply max (list 1.0 2.0))`, false, false, 2.0},
+	{`(apply 1 (list 1))`, false, true, nil},
+	{`(map (lambda (x) (* x x)) (list 1 2 3))`, false, false, []interface{}{int64(1), int64(4), int64(9)}},
+	{`(map + (list 1 2 3) (list 10 20))`, false, false, []interface{}{int64(11), int64(22)}},
+	{`(map str-to-upper "ab")`, false, false, []interface{}{"A", "B"}},
+	{`(filter (lambda (x) (> x 1)) (list 1 2 3))`, false, false, []interface{}{int64(2), int64(3)}},
+	{`(filter (lambda (x) x) (list 1 2 3))`, false, true, nil},
+	{`(reduce + 0 (list 1 2 3))`, false, false, int64(6)},
+	{`(reduce (lambda (a b) (str b a)) "abc")`, false, false, "cba"},
+	{`(reduce + (list))`, false, true, nil},
+	{`(set inc (lambda (x) (+ x 1))) ((compose str inc inc) 1)`, false, false, "3"},
+	{`((lambda (x) (set y (* x 2)) (+ y 1)) 3)`, false, false, int64(7)},
+	{`(map (lambda (x) (if (> x 1) (return x) 0)) (list 1 2))`, false, false, []interface{}{int64(0), int64(2)}},
+	{`(set scale (func (xs k) (map (lambda (x) (* x k)) xs))) (scale (list 1 2 3) 10)`, false, false, []interface{}{int64(10), int64(20), int64(30)}},
+	{`(let ((k 10)) ((lambda (x) (+ x k)) 1))`, false, false, int64(11)},
+	{`(set adder (func (n) (lambda (x) (+ x n)))) (set add5 (adder 5)) (add5 1)`, false, false, int64(6)},
+	{`(set over (func (xs lo) (filter (lambda (x) (> x lo)) xs))) (over (list 1 5 3) 2)`, false, false, []interface{}{int64(5), int64(3)}},
+	{`(set sum-scaled (func (xs k) (reduce (lambda (a b) (+ a (* b k))) 0 xs))) (sum-scaled (list 1 2) 3)`, false, false, int64(9)},
+	{`(let ((k 1)) ((lambda (x) (set k x)) 2) k)`, false, false, int64(1)},
+	{`((lambda (x) x) 5)`, false, false, int64(5)},
+	{`(map (lambda (x) 0) (list 1 2))`, false, false, []interface{}{int64(0), int64(0)}},
+	{`(filter (lambda (x) true) (list 1 2))`, false, false, []interface{}{int64(1), int64(2)}},
+	{`(let ((k 3)) (map (lambda (x) k) (list 1 2)))`, false, false, []interface{}{int64(3), int64(3)}},
+	{`((lambda (x)) 5)`, false, true, nil},
+	{`(set s 0) (foreach (e (list 1 2 3)) (set s (+ s e))) (begin s)`, false, false, int64(6)},
+}
+
+func TestFunctional(t *testing.T) {
+	doStmtTests("TestFunctional", t, functionaltests)
+}
+
+func TestReduceArguments(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	for i, tst := range []struct {
+		src string
+		id  int
+		msg string
+	}{
+		{`(reduce +)`, runtime.ErrorInsufficientNumberOfArguments, "TestReduceArguments:1:1 Insufficient number of arguments(1 given, 2 need)"},
+		{`(reduce + 0 (list 1) 2)`, runtime.ErrorTooManyArguments, "TestReduceArguments:1:1 Too many arguments(4 given, 3 need)"},
+	} {
+		lists, err := parser.ParseString("TestReduceArguments", st, tst.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = runtime.EvalList(lists[0], ns)
+		ee, ok := err.(*runtime.EvalError)
+		if !ok || ee.ID != tst.id || ee.Error() != tst.msg {
+			t.Errorf("[%d]Unexpected error: %v", i, err)
+		}
+	}
+}
+
+var stringtests = []optest{
+	{`(str-len "日本語abc")`, false, false, int64(6)},
+	{`(str-byte-len "日本語abc")`, false, false, int64(12)},
+	{`(str-sub "日本語abc" 1 4)`, false, false, "本語a"},
+	{`(str-sub "日本語abc" 3)`, false, false, "abc"},
+	{`(str-sub "日本語" 3 3)`, false, false, ""},
+	{`(str-sub "日本語" 2 1)`, false, true, nil},
+	{`(str-sub "日本語" 0 4)`, false, true, nil},
+	{`(str-rune-at "日本語" 1)`, false, false, int64('本')},
+	{`(str-rune-at "日本語" 3)`, false, true, nil},
+	{`(str-byte-at "日本語" 0)`, false, false, int64(0xe6)},
+	{`(str-split "A-01-日本" "-")`, false, false, []interface{}{"A", "01", "日本"}},
+	{`(str-split "a,b,c" "," 2)`, false, false, []interface{}{"a", "b,c"}},
+	{`(str-split "あいう" "")`, false, false, []interface{}{"あ", "い", "う"}},
+	{`(str-join (list "a" "b" "c") "、")`, false, false, "a、b、c"},
+	{`(str-join (str-split "x y" " ") "+")`, false, false, "x+y"},
+	{`(str-join (list "a" 1) ",")`, false, true, nil},
+	{`(str-fields "  a\tb  c ")`, false, false, []interface{}{"a", "b", "c"}},
+}
+
+func TestStrings(t *testing.T) {
+	doStmtTests("TestStrings", t, stringtests)
+}
+
+var regexptests = []optest{
+	{`(re-match "^\\d{3}-\\d{4}$" "123-4567")`, false, false, true},
+	{`(re-match "^\\d{3}-\\d{4}$" "1234-567")`, false, false, false},
+	{`(re-find "[0-9]+" "abc123def45")`, false, false, "123"},
+	{`(re-find "[0-9]+" "abc")`, false, false, nil},
+	{`(re-find-all "[0-9]+" "a1b22c333")`, false, false, []interface{}{"1", "22", "333"}},
+	{`(re-find-all "[0-9]+" "a1b22c333" 2)`, false, false, []interface{}{"1", "22"}},
+	{`(re-find-submatch "(\\w+)@(\\w+)?x" "user@x")`, false, false, []interface{}{"user@x", "user", nil}},
+	{`(re-group "(?P<bank>\\d{4})-(?P<branch>\\d{3})" "口座 0123-456" "branch")`, false, false, "456"},
+	{`(re-group "(a)(b)" "ab" 2)`, false, false, "b"},
+	{`(re-group "(a)" "ab" "x")`, false, true, nil},
+	{`(re-replace "(\\w+)-(\\w+)" "ab-cd" "$2-$1")`, false, false, "cd-ab"},
+	{`(re-replace "(?P<y>\\d{4})/(?P<m>\\d{2})" "2024/05" "${m}月${y}年")`, false, false, "05月2024年"},
+	{`(re-split "[,;]\\s*" "a, b;c")`, false, false, []interface{}{"a", "b", "c"}},
+	{`(re-match "(" "x")`, false, true, nil},
+}
+
+func TestRegexp(t *testing.T) {
+	doStmtTests("TestRegexp", t, regexptests)
+}
+
+var unicodetests = []optest{
+	{`(str-width "abc")`, false, false, int64(3)},
+	{`(str-width "日本語ｶﾅ")`, false, false, int64(8)},
+	{`(str-width (str-from-runes 101 0x301))`, false, false, int64(1)},
+	{`(str-to-zenkaku "ABC 123")`, false, false, "ＡＢＣ　１２３"},
+	{`(str-to-zenkaku "ｶﾞｷﾞｸﾞﾊﾟｳﾞｱ")`, false, false, "ガギグパヴア"},
+	{`(str-to-hankaku "ＡＢＣ　１２３ガパ")`, false, false, "ABC 123ｶﾞﾊﾟ"},
+	{`(str-normalize-width "ＡＢＣ１２３ｶﾞｲ")`, false, false, "ABC123ガイ"},
+	{`(eq (str-normalize-width "ﾃｽﾄ１") (str-normalize-width "テスト1"))`, false, false, true},
+	{`(str-to-katakana "ひらがなゞ")`, false, false, "ヒラガナヾ"},
+	{`(str-to-hiragana "カタカナヴ")`, false, false, "かたかなゔ"},
+	{`(str-is-digit "0123")`, false, false, true},
+	{`(str-is-digit "12a")`, false, false, false},
+	{`(str-is-digit "")`, false, false, false},
+	{`(str-is-letter "abcあ")`, false, false, true},
+	{`(str-is-space (str-from-runes 32 9 0x3000))`, false, false, true},
+	{`(str-is-hiragana "ひらがな")`, false, false, true},
+	{`(str-is-katakana "カタカナー")`, false, false, true},
+	{`(str-is-han "漢字")`, false, false, true},
+	{`(str-is-han "漢じ")`, false, false, false},
+	{`(str-to-runes "aあ")`, false, false, []interface{}{int64(97), int64(0x3042)}},
+	{`(str-from-runes 97 (list 0x3042 98))`, false, false, "aあb"},
+	{`(str-from-runes 55296)`, false, true, nil},
+	{`(str-from-runes (list 97 0xDFFF))`, false, true, nil},
+	{`(str-from-runes 0xD7FF 0xE000)`, false, false, "\uD7FF\uE000"},
+	{`(str-from-runes -1)`, false, true, nil},
+	{`(str-from-runes "a")`, false, true, nil},
+}
+
+func TestUnicode(t *testing.T) {
+	doStmtTests("TestUnicode", t, unicodetests)
+}
+
+var interpolationtests = []optest{
+	{`(begin (set x 1234.5) f"Total: {x:.2f} yen")`, false, false, "Total: 1234.50 yen"},
+	{`(begin (set n 3) f"{n} items, {(* n 2)} halves, 100%")`, false, false, "3 items, 6 halves, 100%"},
+	{`(let ((name "ok")) f"[{name:q}] {{literal}}")`, false, false, `["ok"] {literal}`},
+	{`(begin f"{(str-to-upper "a")}\t{(list 1 nil)}")`, false, false, "A\t(1 nil)"},
+	{`(begin f"{(nil)}")`, false, true, nil},
+	{`(begin (set x "s") f"{x:d}")`, false, true, nil},
+}
+
+func TestInterpolation(t *testing.T) {
+	doStmtTests("TestInterpolation", t, interpolationtests)
+}
+
+var timetests = []optest{
+	{`(date 2024 1 2 3 4 5 "UTC")`, false, false, int64(1704164645)},
+	{`(date 2024 1 2 12 4 5 "Asia/Tokyo")`, false, false, int64(1704164645)},
+	{`(date 2024 1 2 3 4 5 "Mars/Olympus")`, false, true, nil},
+	{`(hour 1704164645 "Asia/Tokyo")`, false, false, int64(12)},
+	{`(hour 1704164645 "-05:00")`, false, false, int64(22)},
+	{`(day 1704164645 "-0500")`, false, false, int64(1)},
+	{`(zone 1704164645 "America/New_York")`, false, false, "EST"},
+	{`(zoneoffset 1704164645 "+09:30")`, false, false, int64(34200)},
+	{`(time-format 1704164645 "rfc3339" "UTC")`, false, false, "2024-01-02T03:04:05Z"},
+	{`(time-format 1704164645 "2006/01/02 15:04 MST" "Asia/Tokyo")`, false, false, "2024/01/02 12:04 JST"},
+	{`(time-format 1704164645 "%Y年%m月%d日 %H:%M:%S (%a) 100%%" "Asia/Tokyo")`, false, false, "2024年01月02日 12:04:05 (Tue) 100%"},
+	{`(time-format 1704164645 "%Q" "UTC")`, false, true, nil},
+	{`(time-parse "2024-01-02T12:04:05+09:00")`, false, false, int64(1704164645)},
+	{`(time-format (time-parse "2024-01-02T03:04:05.123Z") "2006-01-02T15:04:05.000Z07:00" "UTC")`, false, false, "2024-01-02T03:04:05.123Z"},
+	{`(time-parse "2024-01-02T03:04:05.5Z")`, false, false, 1704164645.5},
+	{`(- (time-parse "2024-01-02T03:04:05.5Z") (time-parse "2024-01-02T03:04:05.25Z"))`, false, false, 0.25},
+	{`(time-diff (time-parse "2024-01-02T03:04:05.5Z") (time-parse "2024-01-02T03:04:05Z"))`, false, false, 500 * time.Millisecond},
+	{`(time-parse "2024-01-02T12:04:05" "iso8601" "Asia/Tokyo")`, false, false, int64(1704164645)},
+	{`(time-parse "20240102T030405Z")`, false, false, int64(1704164645)},
+	{`(time-parse "2024-01-02" "iso8601" "UTC")`, false, false, int64(1704153600)},
+	{`(time-parse "02/01/2024 03:04:05" "%d/%m/%Y %H:%M:%S" "UTC")`, false, false, int64(1704164645)},
+	{`(time-parse "2024-05-03 at 1" "%Y-%m-%d at 1" "UTC")`, false, false, int64(1714694400)},
+	{`(time-parse "2024-05-03 at 2" "%Y-%m-%d at 1" "UTC")`, false, true, nil},
+	{`(time-parse "Jan 2006: 20240102 030405" "Jan 2006: %Y%m%d %H%M%S" "UTC")`, false, false, int64(1704164645)},
+	{`(time-parse "2024年1月2日 3時4分5秒 PM" "%Y年%m月%d日" "UTC")`, false, true, nil},
+	{`(time-parse "2024年01月02日 03:04 PM" "%Y年%m月%d日 %I:%M %p" "UTC")`, false, false, int64(1704207840)},
+	{`(time-parse "Tue, 02 Jan 2024 03:04:05 GMT" "rfc1123")`, false, false, int64(1704164645)},
+	{`(time-parse "yesterday")`, false, true, nil},
+	{`(eq (time-parse (time-format 1704164645 "rfc3339" "Europe/Paris") "rfc3339") 1704164645)`, false, false, true},
+}
+
+func TestTimeFormat(t *testing.T) {
+	doStmtTests("TestTimeFormat", t, timetests)
+}
+
+var durationtests = []optest{
+	{`(begin 1h30m)`, false, false, 90 * time.Minute},
+	{`(+ 1h 30m -5s)`, false, false, 90*time.Minute - 5*time.Second},
+	{`(- 2h 1h30m)`, false, false, 30 * time.Minute},
+	{`(+ 1h 1)`, false, true, nil},
+	{`(* 1h 2)`, false, true, nil},
+	{`(< 1m 1h 1.5h)`, false, false, true},
+	{`(eq 90m 1h30m)`, false, false, true},
+	{`(str 1h30m)`, false, false, "1h30m0s"},
+	{`(format "%v/%s/%d" 1500ms 2m 3ns)`, false, false, "1.5s/2m0s/3"},
+	{`(is-duration 1h (duration "2m"))`, false, false, true},
+	{`(is-duration 1h 3600)`, false, false, false},
+	{`(duration "1h15m")`, false, false, 75 * time.Minute},
+	{`(duration "15 minutes")`, false, true, nil},
+	{`(duration 90)`, false, false, 90 * time.Second},
+	{`(duration 1.5 "h")`, false, false, 90 * time.Minute},
+	{`(duration 2 "week")`, false, false, 14 * 24 * time.Hour},
+	{`(duration 2 "fortnight")`, false, true, nil},
+	{`(duration 1e300 "h")`, false, true, nil},
+	{`(duration 9223372036 "s")`, false, false, 9223372036 * time.Second},
+	{`(duration 9223372037 "s")`, false, true, nil},
+	{`(duration -9223372037 "s")`, false, true, nil},
+	{`(time-diff (date 2600 1 1 0 0 0 "UTC") (date 2000 1 1 0 0 0 "UTC") "h")`, false, false, int64(5259504)},
+	{`(time-diff (date 2600 1 1 0 0 0 "UTC") (date 2000 1 1 0 0 0 "UTC") "ms")`, false, true, nil},
+	{`(time-diff (date 2600 1 1 0 0 0 "UTC") (date 2000 1 1 0 0 0 "UTC"))`, false, true, nil},
+	{`(time-diff 0 1e18 "day" "UTC")`, false, false, int64(-11574074074074)},
+	{`(time-diff 0 1e18 "year" "UTC")`, false, false, int64(-31688738506)},
+	{`(time-diff 9000000000000000000 -9000000000000000000 "s")`, false, true, nil},
+	{`(time-diff 0 1e300 "s")`, false, true, nil},
+	{`(duration-in 90m "h")`, false, false, 1.5},
+	{`(duration-in 48h "day")`, false, false, 2.0},
+	{`(duration-in (duration 3 "day") "week")`, false, false, 3.0 / 7},
+	{`(duration-in 1h "month")`, false, true, nil},
+	{`(time-add 1704164645 1h 30m)`, false, false, int64(1704170045)},
+	{`(time-add 1704164645 500ms)`, false, false, 1704164645.5},
+	{`(time-add 1704164645.5 500ms)`, false, false, int64(1704164646)},
+	{`(time-add 9223372036854775807 1h)`, false, true, nil},
+	{`(time-add 9223372036854775807 -1h 2h)`, false, true, nil},
+	{`(time-add (- -9223372036854775807 1) -1ns)`, false, true, nil},
+	{`(time-add 9223372036854771807 1h -1h)`, false, false, int64(9223372036854771807)},
+	{`(- -9223372036854775807ns 2ns)`, false, true, nil},
+	{`(+ 9223372036854775807ns 1ns)`, false, true, nil},
+	{`(- 0ns -9223372036854775807ns)`, false, false, time.Duration(9223372036854775807)},
+	{`(add-date 0 9223372036854775807 0 0 "UTC")`, false, true, nil},
+	{`(add-date 0 300000000000 0 0 "UTC")`, false, true, nil},
+	{`(add-date 0 0 -9223372036854775807 0 "UTC")`, false, true, nil},
+	{`(add-date 0 0 0 100000000000 "UTC")`, false, false, int64(8640000000000000)},
+	{`(add-date 0 -2000 0 0 "UTC")`, false, false, int64(-63113904000)},
+	{`(time-format (date 2024 1 2 3 4 5.25 "UTC") "15:04:05.00" "UTC")`, false, false, "03:04:05.25"},
+	{`(time-format (time-truncate (date 2024 1 2 3 4 5.678 "UTC") "ms" "UTC") "15:04:05.000000" "UTC")`, false, false, "03:04:05.678000"},
+	{`(time-format (time-round (date 2024 1 2 3 4 5.678 "UTC") "s" "UTC") "15:04:05.000" "UTC")`, false, false, "03:04:06.000"},
+	{`(time-format (time-truncate (date 2024 1 2 3 4 5.678 "UTC") "h" "UTC") "15:04:05.000" "UTC")`, false, false, "03:00:00.000"},
+	{`(time-format (add-date (date 2024 1 31 9 0 0 "UTC") 0 1 0 "UTC") "date" "UTC")`, false, false, "2024-02-29"},
+	{`(time-format (add-date (date 2023 2 28 9 0 0 "UTC") 1 0 1 "UTC") "date" "UTC")`, false, false, "2024-02-29"},
+	{`(time-format (add-date (date 2024 3 30 12 0 0 "Europe/Paris") 0 0 1 "Europe/Paris") "datetime" "Europe/Paris")`, false, false, "2024-03-31 12:00:00"},
+	{`(time-diff (date 2024 1 2 0 0 0 "UTC") (date 2024 1 1 12 0 0 "UTC"))`, false, false, 12 * time.Hour},
+	{`(time-diff (date 2024 1 2 0 0 0 "UTC") (date 2024 1 1 12 0 0 "UTC") "m")`, false, false, int64(720)},
+	{`(time-diff (date 2024 3 31 0 0 0 "UTC") (date 2024 1 31 0 0 0 "UTC") "month" "UTC")`, false, false, int64(2)},
+	{`(time-diff (date 2024 3 30 0 0 0 "UTC") (date 2024 1 31 0 0 0 "UTC") "month" "UTC")`, false, false, int64(1)},
+	{`(time-diff (date 2023 1 31 0 0 0 "UTC") (date 2024 1 30 0 0 0 "UTC") "year" "UTC")`, false, false, int64(0)},
+	{`(time-diff (date 2024 4 1 0 0 0 "Europe/Paris") (date 2024 3 31 0 0 0 "Europe/Paris") "day" "Europe/Paris")`, false, false, int64(1)},
+	{`(time-diff (date 2024 1 1 0 0 0 "UTC") (date 2024 1 15 0 0 0 "UTC") "week" "UTC")`, false, false, int64(-2)},
+	{`(time-diff 0 0 "fortnight")`, false, true, nil},
+	{`(time-format (time-truncate (date 2024 5 16 13 45 10 "UTC") "week" "UTC") "datetime" "UTC")`, false, false, "2024-05-13 00:00:00"},
+	{`(time-format (time-truncate (date 2024 5 16 13 45 10 "Asia/Tokyo") "month" "Asia/Tokyo") "datetime" "Asia/Tokyo")`, false, false, "2024-05-01 00:00:00"},
+	{`(time-format (time-round (date 2024 5 16 13 45 10 "UTC") "h" "UTC") "datetime" "UTC")`, false, false, "2024-05-16 14:00:00"},
+	{`(time-format (time-round (date 2024 5 16 11 59 59 "UTC") "day" "UTC") "datetime" "UTC")`, false, false, "2024-05-16 00:00:00"},
+	{`(time-truncate 1704164645.75 "s")`, false, false, int64(1704164645)},
+}
+
+func TestDuration(t *testing.T) {
+	doStmtTests("TestDuration", t, durationtests)
+}
+
+var calendartests = []optest{
+	{`(is-business-day (date 2024 5 17 9 0 0 "UTC") "" "UTC")`, false, false, true},
+	{`(is-business-day (date 2024 5 18 9 0 0 "UTC") "" "UTC")`, false, false, false},
+	{`(is-business-day (date 2024 2 12 9 0 0 "Asia/Tokyo") "jp" "Asia/Tokyo")`, false, false, false},
+	{`(is-business-day (date 2024 2 12 9 0 0 "Asia/Tokyo") "" "Asia/Tokyo")`, false, false, true},
+	{`(is-business-day (date 2024 2 11 20 0 0 "UTC") "jp" "Asia/Tokyo")`, false, false, false},
+	{`(is-business-day 0 "nowhere")`, false, true, nil},
+	{`(holiday-name (date 2024 9 23 0 0 0 "UTC") "jp" "UTC")`, false, false, "振替休日"},
+	{`(holiday-name (date 2024 11 4 0 0 0 "UTC") "jp" "UTC")`, false, false, "振替休日"},
+	{`(holiday-name (date 2019 4 30 0 0 0 "UTC") "jp" "UTC")`, false, false, "国民の休日"},
+	{`(holiday-name (date 2019 5 2 0 0 0 "UTC") "jp" "UTC")`, false, false, "国民の休日"},
+	{`(holiday-name (date 2021 7 23 0 0 0 "UTC") "jp" "UTC")`, false, false, "スポーツの日"},
+	{`(holiday-name (date 2024 3 20 0 0 0 "UTC") "jp" "UTC")`, false, false, "春分の日"},
+	{`(holiday-name (date 2024 5 7 0 0 0 "UTC") "jp" "UTC")`, false, false, nil},
+	{`(time-format (next-business-day (date 2024 5 2 9 30 0 "UTC") "jp" "UTC") "datetime" "UTC")`, false, false, "2024-05-07 09:30:00"},
+	{`(time-format (next-business-day (date 2024 5 2 9 30 0 "UTC") "" "UTC") "datetime" "UTC")`, false, false, "2024-05-03 09:30:00"},
+	{`(time-format (add-business-days (date 2024 4 26 0 0 0 "UTC") 3 "jp" "UTC") "date" "UTC")`, false, false, "2024-05-02"},
+	{`(time-format (add-business-days (date 2024 5 7 0 0 0 "UTC") -2 "jp" "UTC") "date" "UTC")`, false, false, "2024-05-01"},
+	{`(add-business-days 1704164645 0 "jp" "UTC")`, false, false, int64(1704164645)},
+	{`(business-days-between (date 2024 4 26 0 0 0 "UTC") (date 2024 5 7 0 0 0 "UTC") "jp" "UTC")`, false, false, int64(4)},
+	{`(business-days-between (date 2024 5 7 0 0 0 "UTC") (date 2024 4 26 0 0 0 "UTC") "jp" "UTC")`, false, false, int64(-4)},
+	{`(business-days-between (date 2024 1 1 0 0 0 "UTC") (date 2025 1 1 0 0 0 "UTC") "" "UTC")`, false, false, int64(262)},
+	{`(business-days-between (date 2024 1 1 0 0 0 "UTC") (date 2025 1 1 0 0 0 "UTC") "jp" "UTC")`, false, false, int64(248)},
+	{`(business-days-between 0 1000000000000000 "" "UTC")`, false, false, int64(8267195766)},
+	{`(business-days-between 0 1000000000000000 "jp" "UTC")`, false, true, nil},
+	{`(time-format (add-business-days (date 2024 5 18 0 0 0 "UTC") 5 "" "UTC") "date" "UTC")`, false, false, "2024-05-24"},
+	{`(time-format (add-business-days (date 2024 5 18 0 0 0 "UTC") -5 "" "UTC") "date" "UTC")`, false, false, "2024-05-13"},
+	{`(time-format (add-business-days (date 2024 5 15 0 0 0 "UTC") 12 "" "UTC") "date" "UTC")`, false, false, "2024-05-31"},
+	{`(time-format (add-business-days 0 1000000000 "" "UTC") "date" "UTC")`, false, false, "3835039-10-24"},
+	{`(add-business-days 0 1000000000 "jp" "UTC")`, false, true, nil},
+	{`(add-business-days 0 -1000000000000000 "" "UTC")`, false, true, nil},
+}
+
+func TestCalendar(t *testing.T) {
+	doStmtTests("TestCalendar", t, calendartests)
+}
+
+func TestHolidayCalendarFile(t *testing.T) {
+	cal, err := runtime.ParseHolidayCalendar("company.txt", strings.NewReader(`# 会社の休日
+include jp
+2024-08-13 夏季休業
+12-31 年末休業
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		year  int
+		month time.Month
+		day   int
+		name  string
+		ok    bool
+	}{
+		{2024, 8, 13, "夏季休業", true},
+		{2025, 8, 13, "", false},
+		{2030, 12, 31, "年末休業", true},
+		{2024, 1, 1, "元日", true},
+		{2024, 1, 2, "", false},
+	}
+	for i, tst := range tests {
+		if name, ok := cal.Holiday(tst.year, tst.month, tst.day); name != tst.name || ok != tst.ok {
+			t.Errorf("[%d]The expected value was %v %v, but the result was %v %v.", i, tst.name, tst.ok, name, ok)
+		}
+	}
+
+	runtime.RegisterHolidayCalendar("test-company", cal)
+	doStmtTests("TestHolidayCalendarFile", t, []optest{
+		{`(is-business-day (date 2024 8 13 9 0 0 "UTC") "test-company" "UTC")`, false, false, false},
+		{`(business-days-between (date 2024 12 27 0 0 0 "UTC") (date 2025 1 6 0 0 0 "UTC") "test-company" "UTC")`, false, false, int64(4)},
+	})
+
+	_, err = runtime.ParseHolidayCalendar("bad.txt", strings.NewReader("2024-08-13 夏季休業\n\n2024-13-01 休み\n"))
+	ee, ok := err.(*runtime.EvalError)
+	if !ok || ee.ID != runtime.ErrorInvalidHolidayEntry || ee.ErrorLocation.Line != 3 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	_, err = runtime.ParseHolidayCalendar("bad.txt", strings.NewReader("include nowhere\n"))
+	if ee, ok := err.(*runtime.EvalError); !ok || ee.ID != runtime.ErrorUnknownHolidayCalendar {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+var clocktests = []optest{
+	{`(with-now 1704164645 (now))`, false, false, int64(1704164645)},
+	{`(set f (func () (- (now) 5))) (with-now 1704164645 (f))`, false, false, int64(1704164640)},
+	{`(with-now 1704164645 (now)) (eq (now) 1704164645)`, false, false, false},
+	{`(with-now "today" (now))`, false, true, nil},
+	{`(with-now 0)`, false, true, nil},
+	{`(with-time-zone "Asia/Tokyo" (hour 1704164645))`, false, false, int64(12)},
+	{`(with-time-zone "UTC" (time-format (date 2024 1 2 3 4 5)))`, false, false, "2024-01-02T03:04:05Z"},
+	{`(with-time-zone "+09:00" (with-time-zone "UTC" (hour 1704164645)) (hour 1704164645))`, false, false, int64(12)},
+	{`(with-time-zone "Asia/Tokyo" (hour 1704164645 "Local"))`, false, false, int64(12)},
+	{`(with-time-zone "Asia/Tokyo" (is-business-day (date 2024 2 12 9 0 0) "jp"))`, false, false, false},
+	{`(with-time-zone "Nowhere/Nothing" 1)`, false, true, nil},
+	{`(with-now 1704164645 (with-time-zone "UTC" (time-format (now) "datetime")))`, false, false, "2024-01-02 03:04:05"},
+}
+
+func TestClock(t *testing.T) {
+	doStmtTests("TestClock", t, clocktests)
+}
+
+func TestInjectedClock(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	ns.Config().Clock = func() time.Time { return time.Unix(1704164645, 0) }
+	ns.Config().Location = time.FixedZone("JST", 9*60*60)
+	runtime.MakeDefaultNamespace(ns)
+	lists, err := parser.ParseString("TestInjectedClock", st, `(time-format (now) "datetime") (date 2024 1 2 12 4 5) (with-now 0 (now)) (now)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{"2024-01-02 12:04:05", int64(1704164645), int64(0), int64(1704164645)}
+	for i, l := range lists {
+		result, err := runtime.EvalList(l, ns)
+		if err != nil || !reflect.DeepEqual(result, expected[i]) {
+			t.Errorf("[%d]The expected value was %v, but the result was %v %v.", i, expected[i], result, err)
+		}
+	}
+}
+
+func TestDefaultCalendar(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	ns.Config().Calendar = runtime.JapaneseHolidayCalendar
+	runtime.MakeDefaultNamespace(ns)
+	lists, err := parser.ParseString("TestDefaultCalendar", st, `(is-business-day (date 2024 2 12 9 0 0 "Asia/Tokyo"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := runtime.EvalList(lists[0], ns)
+	if err != nil || result != runtime.BoolValue(false, ns) {
+		t.Errorf("Unexpected result: %v %v", result, err)
+	}
+}
+
+func TestInvalidRegexpPosition(t *testing.T) {
+	st := parser.NewSymbolTable()
+	ns := runtime.NewRootNamespace(st)
+	runtime.MakeDefaultNamespace(ns)
+	lists, err := parser.ParseString("TestInvalidRegexpPosition", st, `(re-find "a" (re-find "[a" "b"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runtime.EvalList(lists[0], ns)
+	ee, ok := err.(*runtime.EvalError)
+	if !ok || ee.ID != runtime.ErrorInvalidRegexp || ee.ErrorLocation.Column != 23 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
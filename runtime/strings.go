@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"strings"
+	"unicode/utf8"
 
 	"github.com/healthy-tiger/scalc/parser"
 )
@@ -31,6 +32,14 @@ const (
 	trimRightSymbol     = "str-trim-right"
 	trimSpaceSymbol     = "str-trim-space"
 	trimSuffixSymbol    = "str-trim-suffix"
+	lenSymbol           = "str-len"
+	byteLenSymbol       = "str-byte-len"
+	substrSymbol        = "str-sub"
+	runeAtSymbol        = "str-rune-at"
+	byteAtSymbol        = "str-byte-at"
+	splitSymbol         = "str-split"
+	joinSymbol          = "str-join"
+	fieldsSymbol        = "str-fields"
 )
 
 func toNaturalString(s string) []rune {
@@ -129,7 +138,7 @@ func containsBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{},
 	if berr != nil {
 		return nil, berr
 	}
-	return BoolToInt(strings.Contains(a, b)), nil
+	return BoolValue(strings.Contains(a, b), ns), nil
 }
 
 func containsAnyBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -144,7 +153,7 @@ func containsAnyBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{
 	if berr != nil {
 		return nil, berr
 	}
-	return BoolToInt(strings.ContainsAny(a, b)), nil
+	return BoolValue(strings.ContainsAny(a, b), ns), nil
 }
 
 func countBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -174,7 +183,7 @@ func equalFoldBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{},
 	if berr != nil {
 		return nil, berr
 	}
-	return BoolToInt(strings.EqualFold(a, b)), nil
+	return BoolValue(strings.EqualFold(a, b), ns), nil
 }
 
 func hasPrefixBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -189,7 +198,7 @@ func hasPrefixBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{},
 	if berr != nil {
 		return nil, berr
 	}
-	return BoolToInt(strings.HasPrefix(a, b)), nil
+	return BoolValue(strings.HasPrefix(a, b), ns), nil
 }
 
 func hasSuffixBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -204,7 +213,7 @@ func hasSuffixBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{},
 	if berr != nil {
 		return nil, berr
 	}
-	return BoolToInt(strings.HasSuffix(a, b)), nil
+	return BoolValue(strings.HasSuffix(a, b), ns), nil
 }
 
 func indexBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
@@ -470,6 +479,176 @@ func trimSuffixBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}
 	return strings.TrimSuffix(a, b), nil
 }
 
+// lenBody 文字列の文字（rune）数を返す。
+func lenBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return int64(utf8.RuneCountInString(a)), nil
+}
+
+// byteLenBody 文字列のUTF-8でのバイト数を返す。
+func byteLenBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return int64(len(a)), nil
+}
+
+// evalAsIndex 名前空間nsでelmを評価し、0からmaxまでの範囲の整数として返す。
+func evalAsIndex(elm parser.SyntaxElement, ns *Namespace, max int) (int, error) {
+	i, err := EvalAsInt(elm, ns)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 || i > int64(max) {
+		return 0, NewEvalError(elm.Position(), ErrorValueOutOfRange, i, 0, max)
+	}
+	return int(i), nil
+}
+
+// substrBody (str-sub 文字列 開始 [終了])
+// 文字（rune）単位の位置で開始から終了の直前までの部分文字列を返す。終了を省略した場合は末尾までになる。
+func substrBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 && lst.Len() != 4 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	rs := []rune(a)
+	end := len(rs)
+	start, err := evalAsIndex(lst.ElementAt(2), ns, end)
+	if err != nil {
+		return nil, err
+	}
+	if lst.Len() == 4 {
+		end, err = evalAsIndex(lst.ElementAt(3), ns, len(rs))
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, NewEvalError(lst.ElementAt(3).Position(), ErrorValueOutOfRange, end, start, len(rs))
+		}
+	}
+	return string(rs[start:end]), nil
+}
+
+// runeAtBody (str-rune-at 文字列 位置)
+// 文字（rune）単位の位置にある文字のコードポイントを返す。
+func runeAtBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	rs := []rune(a)
+	i, err := evalAsIndex(lst.ElementAt(2), ns, len(rs)-1)
+	if err != nil {
+		return nil, err
+	}
+	return int64(rs[i]), nil
+}
+
+// byteAtBody (str-byte-at 文字列 位置)
+// バイト単位の位置にあるUTF-8のバイトの値を返す。
+func byteAtBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	i, err := evalAsIndex(lst.ElementAt(2), ns, len(a)-1)
+	if err != nil {
+		return nil, err
+	}
+	return int64(a[i]), nil
+}
+
+// stringsToList 文字列のスライスをリストの値に変換する。
+func stringsToList(ss []string) []interface{} {
+	result := make([]interface{}, len(ss))
+	for i, s := range ss {
+		result[i] = s
+	}
+	return result
+}
+
+// splitBody (str-split 文字列 区切り [最大数])
+// 区切りで分割した文字列のリストを返す。最大数を指定した場合は最大数個までに分割し、最後の要素に残りをすべて含める。
+func splitBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 && lst.Len() != 4 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	b, berr := EvalAsString(lst.ElementAt(2), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	if berr != nil {
+		return nil, berr
+	}
+	n := int64(-1)
+	if lst.Len() == 4 {
+		var err error
+		n, err = EvalAsInt(lst.ElementAt(3), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stringsToList(strings.SplitN(a, b, int(n))), nil
+}
+
+// joinBody (str-join コレクション 区切り)
+// コレクションの文字列の要素を区切りで連結した文字列を返す。
+func joinBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 3 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	c, cerr := evalAsCollection(lst.ElementAt(1), ns)
+	b, berr := EvalAsString(lst.ElementAt(2), ns)
+	if cerr != nil {
+		return nil, cerr
+	}
+	if berr != nil {
+		return nil, berr
+	}
+	ss := make([]string, len(c))
+	for i, e := range c {
+		s, ok := e.(string)
+		if !ok {
+			return nil, NewEvalError(lst.ElementAt(1).Position(), ErrorOperantsMustBeOfStringType, e)
+		}
+		ss[i] = s
+	}
+	return strings.Join(ss, b), nil
+}
+
+// fieldsBody 空白文字の並びで分割した文字列のリストを返す。
+func fieldsBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return stringsToList(strings.Fields(a)), nil
+}
+
 // RegisterStrings stに演算子のシンボルを、nsに演算子に対応する拡張関数をそれぞれ登録する。
 func RegisterStrings(ns *Namespace) {
 	ns.RegisterExtension(strCmpSymbol, nil, strCmpBody)
@@ -496,4 +675,12 @@ func RegisterStrings(ns *Namespace) {
 	ns.RegisterExtension(trimRightSymbol, nil, trimRightBody)
 	ns.RegisterExtension(trimSpaceSymbol, nil, trimSpaceBody)
 	ns.RegisterExtension(trimSuffixSymbol, nil, trimSuffixBody)
+	ns.RegisterExtension(lenSymbol, nil, lenBody)
+	ns.RegisterExtension(byteLenSymbol, nil, byteLenBody)
+	ns.RegisterExtension(substrSymbol, nil, substrBody)
+	ns.RegisterExtension(runeAtSymbol, nil, runeAtBody)
+	ns.RegisterExtension(byteAtSymbol, nil, byteAtBody)
+	ns.RegisterExtension(splitSymbol, nil, splitBody)
+	ns.RegisterExtension(joinSymbol, nil, joinBody)
+	ns.RegisterExtension(fieldsSymbol, nil, fieldsBody)
 }
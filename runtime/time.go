@@ -21,13 +21,16 @@ const (
 	zoneoffsetSymbol = "zoneoffset"
 )
 
+// dateBody (date 年 月 日 時 分 秒 [タイムゾーン])
+// タイムゾーンの日時をUNIX時間に変換する。タイムゾーンを省略した場合は既定のタイムゾーン（通常はローカルタイム）とする。
+// 秒に秒未満の部分がある場合は浮動小数点数のUNIX時間を返す。
 func dateBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 7 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 6)
+	if lst.Len() != 7 && lst.Len() != 8 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 7)
 	}
 
-	params := make([]interface{}, lst.Len())
-	for i := 1; i < lst.Len(); i++ {
+	params := make([]interface{}, 7)
+	for i := 1; i < 7; i++ {
 		ev, err := EvalElement(lst.ElementAt(i), ns)
 		if err != nil {
 			return nil, err
@@ -59,133 +62,114 @@ func dateBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, erro
 	if !ok {
 		return nil, NewEvalError(lst.ElementAt(5).Position(), ErrorOperantsMustBeOfIntegerType, params[5])
 	}
-	sec, ok := params[6].(int64)
-	if !ok {
-		return nil, NewEvalError(lst.ElementAt(6).Position(), ErrorOperantsMustBeOfIntegerType, params[6])
+	// 秒は浮動小数点数で秒未満の部分も指定できる。
+	var sec, nanosec int64
+	switch s := params[6].(type) {
+	case int64:
+		sec = s
+	case float64:
+		t, err := toTime(s, lst.ElementAt(6).Position())
+		if err != nil {
+			return nil, err
+		}
+		sec, nanosec = t.Unix(), int64(t.Nanosecond())
+	default:
+		return nil, NewEvalError(lst.ElementAt(6).Position(), ErrorOperantsMustBeNumeric, params[6])
+	}
+	loc, err := evalLocation(lst, 7, ns)
+	if err != nil {
+		return nil, err
 	}
-	nanosec := int64(0)
 
-	return time.Date(int(year), time.Month(month), int(day), int(hour), int(min), int(sec), int(nanosec), time.Local).Unix(), nil
+	return timeValue(time.Date(int(year), time.Month(month), int(day), int(hour), int(min), int(sec), int(nanosec), loc)), nil
 }
 
 func nowBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
 	if lst.Len() != 1 {
 		return nil, NewEvalError(lst.Position(), ErrorTooManyArguments, lst.Len()-1, 0)
 	}
-	return time.Now().Unix(), nil
+	return ns.Now().Unix(), nil
 }
 
 func dayBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Day()), nil
+	return int64(t.Day()), nil
 }
 
 func hourBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Hour()), nil
+	return int64(t.Hour()), nil
 }
 
 func minuteBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Minute()), nil
+	return int64(t.Minute()), nil
 }
 
 func monthBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Month()), nil
+	return int64(t.Month()), nil
 }
 
 func secondBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Second()), nil
+	return int64(t.Second()), nil
 }
 
 func weekdayBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Weekday()), nil
+	return int64(t.Weekday()), nil
 }
 
 func yearBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).Year()), nil
+	return int64(t.Year()), nil
 }
 
 func yeardayBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	return int64(time.Unix(sec, 0).YearDay()), nil
+	return int64(t.YearDay()), nil
 
 }
 
 func zoneBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	t := time.Unix(sec, 0)
 	z, _ := t.Zone()
 	return z, nil
 }
 
 func zoneoffsetBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
-	if lst.Len() != 2 {
-		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
-	}
-	sec, err := EvalAsInt(lst.ElementAt(1), ns)
+	t, err := evalTime(lst, ns)
 	if err != nil {
 		return nil, err
 	}
-	t := time.Unix(sec, 0)
 	_, o := t.Zone()
 	return int64(o), nil
 }
@@ -204,4 +188,6 @@ func RegisterTimeFunc(ns *Namespace) {
 	ns.RegisterExtension(yeardaySymbol, nil, yeardayBody)
 	ns.RegisterExtension(zoneSymbol, nil, zoneBody)
 	ns.RegisterExtension(zoneoffsetSymbol, nil, zoneoffsetBody)
+	ns.RegisterExtension(timeFormatSymbol, nil, timeFormatBody)
+	ns.RegisterExtension(timeParseSymbol, nil, timeParseBody)
 }
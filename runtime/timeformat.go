@@ -0,0 +1,392 @@
+package runtime
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // 実行環境のタイムゾーンのデータベースの有無や版に関わらず同じ結果になるようにする。
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	timeFormatSymbol = "time-format"
+	timeParseSymbol  = "time-parse"
+)
+
+// 時刻の書式化に関するエラーコード
+var (
+	ErrorUnknownTimeZone   int
+	ErrorInvalidTimeLayout int
+	ErrorCannotParseTime   int
+)
+
+func init() {
+	ErrorUnknownTimeZone = RegisterEvalError("Unknown time zone %v")
+	ErrorInvalidTimeLayout = RegisterEvalError("Invalid time layout %v")
+	ErrorCannotParseTime = RegisterEvalError("Cannot parse %v as a time: %v")
+
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorUnknownTimeZone, "タイムゾーン %v が見つかりません")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorInvalidTimeLayout, "時刻の書式 %v が不正です")
+	RegisterEvalErrorMessage(parser.LocaleJapanese, ErrorCannotParseTime, "%v を時刻として解釈できません: %v")
+}
+
+// iso8601Layout ISO 8601形式の名前。time-parseではiso8601Layoutsのいずれかに一致すればよい。
+const iso8601Layout = "iso8601"
+
+// timeLayouts 名前で指定できる時刻の書式
+var timeLayouts = map[string]string{
+	iso8601Layout: "2006-01-02T15:04:05Z07:00",
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"rfc822z":     time.RFC822Z,
+	"rfc850":      time.RFC850,
+	"ansic":       time.ANSIC,
+	"unixdate":    time.UnixDate,
+	"kitchen":     time.Kitchen,
+	"date":        "2006-01-02",
+	"time":        "15:04:05",
+	"datetime":    "2006-01-02 15:04:05",
+}
+
+// iso8601Layouts time-parseでISO 8601形式として受け付ける書式。秒の小数部は書式になくても受け付けられる。
+var iso8601Layouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"20060102T150405Z0700",
+	"20060102T150405",
+	"20060102",
+}
+
+// strftimeDirectives strftime形式の変換指定に対応するGoの書式
+var strftimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'z': "-0700",
+	'Z': "MST",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+	'R': "15:04",
+	'D': "01/02/06",
+}
+
+// strftimePatterns time-parseでstrftime形式の変換指定に一致する文字列の正規表現
+var strftimePatterns = map[byte]string{
+	'Y': `[0-9]{4}`,
+	'y': `[0-9]{2}`,
+	'm': `[0-9]{2}`,
+	'd': `[0-9]{2}`,
+	'e': `[ 0-9]?[0-9]`,
+	'H': `[0-9]{1,2}`,
+	'I': `[0-9]{2}`,
+	'M': `[0-9]{2}`,
+	'S': `[0-9]{2}(?:[.,][0-9]+)?`,
+	'p': `[AP]M`,
+	'b': `[A-Za-z]{3}`,
+	'h': `[A-Za-z]{3}`,
+	'B': `[A-Za-z]+`,
+	'a': `[A-Za-z]{3}`,
+	'A': `[A-Za-z]+`,
+	'z': `[+-][0-9]{4}`,
+	'Z': `[A-Za-z]{3,5}|[+-][0-9]+`,
+	'F': `[0-9]{4}-[0-9]{2}-[0-9]{2}`,
+	'T': `[0-9]{2}:[0-9]{2}:[0-9]{2}(?:[.,][0-9]+)?`,
+	'R': `[0-9]{2}:[0-9]{2}`,
+	'D': `[0-9]{2}/[0-9]{2}/[0-9]{2}`,
+}
+
+// timePiece strftime形式の書式の一部。layoutがtrueの場合、textは変換指定directiveに対応するGoの書式を表す。
+type timePiece struct {
+	text      string
+	layout    bool
+	directive byte
+}
+
+// parseStrftime strftime形式の書式patternを、そのまま出力する文字列とGoの書式に分ける。
+func parseStrftime(pattern string, pos parser.Position) ([]timePiece, error) {
+	pieces := make([]timePiece, 0)
+	start := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			continue
+		}
+		if start < i {
+			pieces = append(pieces, timePiece{pattern[start:i], false, 0})
+		}
+		if i+1 >= len(pattern) {
+			return nil, NewEvalError(pos, ErrorInvalidTimeLayout, pattern)
+		}
+		i++
+		switch c := pattern[i]; c {
+		case '%':
+			pieces = append(pieces, timePiece{"%", false, 0})
+		case 'n':
+			pieces = append(pieces, timePiece{"\n", false, 0})
+		case 't':
+			pieces = append(pieces, timePiece{"\t", false, 0})
+		default:
+			l, ok := strftimeDirectives[c]
+			if !ok {
+				return nil, NewEvalError(pos, ErrorInvalidTimeLayout, pattern[i-1:i+1])
+			}
+			pieces = append(pieces, timePiece{l, true, c})
+		}
+		start = i + 1
+	}
+	if start < len(pattern) {
+		pieces = append(pieces, timePiece{pattern[start:], false, 0})
+	}
+	return pieces, nil
+}
+
+// formatTime 時刻tを書式layoutで書式化する。layoutは書式の名前、strftime形式（'%'を含む場合）、Goの書式のいずれか。
+func formatTime(t time.Time, layout string, pos parser.Position) (string, error) {
+	if l, ok := timeLayouts[layout]; ok {
+		return t.Format(l), nil
+	}
+	if !strings.ContainsRune(layout, '%') {
+		return t.Format(layout), nil
+	}
+	pieces, err := parseStrftime(layout, pos)
+	if err != nil {
+		return "", err
+	}
+	// 固定の文字列をGoの書式として解釈しないように、変換指定ごとに書式化する。
+	var b strings.Builder
+	for _, p := range pieces {
+		if p.layout {
+			b.WriteString(t.Format(p.text))
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return b.String(), nil
+}
+
+// parseTime 文字列sを書式layoutで時刻として解釈する。時差を含まない場合はlocの時刻とする。
+func parseTime(s string, layout string, loc *time.Location, pos parser.Position) (time.Time, error) {
+	layouts := []string{layout}
+	if layout == iso8601Layout {
+		layouts = iso8601Layouts
+	} else if l, ok := timeLayouts[layout]; ok {
+		layouts = []string{l}
+	} else if strings.ContainsRune(layout, '%') {
+		pieces, err := parseStrftime(layout, pos)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := parseStrftimeTime(s, layout, pieces, loc)
+		if err != nil {
+			return time.Time{}, NewEvalError(pos, ErrorCannotParseTime, strconv.Quote(s), err)
+		}
+		return t, nil
+	}
+	var perr error
+	for _, l := range layouts {
+		t, err := time.ParseInLocation(l, s, loc)
+		if err == nil {
+			return t, nil
+		}
+		if perr == nil {
+			perr = err
+		}
+	}
+	return time.Time{}, NewEvalError(pos, ErrorCannotParseTime, strconv.Quote(s), perr)
+}
+
+// parseStrftimeTime strftime形式の書式patternを分けたpiecesで文字列sを時刻として解釈する。
+// 固定の文字列をGoの書式として解釈しないように、正規表現でsを変換指定ごとの部分に分け、変換指定の部分だけをGoの書式で解釈する。
+func parseStrftimeTime(s string, pattern string, pieces []timePiece, loc *time.Location) (time.Time, error) {
+	var expr strings.Builder
+	layouts := make([]string, 0, len(pieces))
+	expr.WriteString("^")
+	for _, p := range pieces {
+		if p.layout {
+			expr.WriteString("(" + strftimePatterns[p.directive] + ")")
+			layouts = append(layouts, p.text)
+		} else {
+			expr.WriteString(regexp.QuoteMeta(p.text))
+		}
+	}
+	expr.WriteString("$")
+	m := regexp.MustCompile(expr.String()).FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, &time.ParseError{Layout: pattern, Value: s, Message: ": does not match " + strconv.Quote(pattern)}
+	}
+	// 変換指定の部分どうしは、Goの書式として意味を持たない区切り文字でつなぐ。
+	t, err := time.ParseInLocation(strings.Join(layouts, "\x00"), strings.Join(m[1:], "\x00"), loc)
+	if perr, ok := err.(*time.ParseError); ok {
+		// 区切り文字を含まないように、元の文字列と書式でエラーを作り直す。
+		msg := perr.Message
+		if msg == "" {
+			msg = ": cannot parse " + strconv.Quote(perr.ValueElem) + " as " + strconv.Quote(perr.LayoutElem)
+		}
+		return time.Time{}, &time.ParseError{Layout: pattern, Value: s, Message: msg}
+	}
+	return t, err
+}
+
+var (
+	locationsLock sync.Mutex
+	// locations 読み込み済みのIANAタイムゾーン
+	locations = make(map[string]*time.Location)
+)
+
+// loadLocation タイムゾーンnameを返す。nameはIANAタイムゾーン名（Asia/Tokyoなど）、UTC、Local、
+// または+09:00や-0500のような時差のいずれか。Localと空文字列はlocalを返す。
+func loadLocation(name string, local *time.Location) (*time.Location, bool) {
+	switch name {
+	case "", "Local":
+		return local, true
+	case "UTC", "Z":
+		return time.UTC, true
+	}
+	if offset, ok := parseZoneOffset(name); ok {
+		return time.FixedZone(name, offset), true
+	}
+	locationsLock.Lock()
+	defer locationsLock.Unlock()
+	if loc, ok := locations[name]; ok {
+		return loc, true
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	locations[name] = loc
+	return loc, true
+}
+
+// parseZoneOffset +09:00、-0500、+09のような時差を秒数に変換する。
+func parseZoneOffset(s string) (int, bool) {
+	if len(s) < 3 || (s[0] != '+' && s[0] != '-') {
+		return 0, false
+	}
+	digits := strings.Replace(s[1:], ":", "", 1)
+	if len(digits) != 2 && len(digits) != 4 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || strings.ContainsAny(digits, "+-") {
+		return 0, false
+	}
+	h, m := n, 0
+	if len(digits) == 4 {
+		h, m = n/100, n%100
+	}
+	if h > 23 || m > 59 {
+		return 0, false
+	}
+	offset := (h*60 + m) * 60
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// evalLocation lstのindex番目の要素があれば評価してタイムゾーンとして返す。なければns.Location()を返す。
+func evalLocation(lst *parser.List, index int, ns *Namespace) (*time.Location, error) {
+	if lst.Len() <= index {
+		return ns.Location(), nil
+	}
+	name, err := EvalAsString(lst.ElementAt(index), ns)
+	if err != nil {
+		return nil, err
+	}
+	loc, ok := loadLocation(name, ns.Location())
+	if !ok {
+		return nil, NewEvalError(lst.ElementAt(index).Position(), ErrorUnknownTimeZone, name)
+	}
+	return loc, nil
+}
+
+// evalTime (関数名 時刻 [タイムゾーン])の形式のlstを評価し、UNIX時間の時刻をタイムゾーンの時刻に変換して返す。
+func evalTime(lst *parser.List, ns *Namespace) (time.Time, error) {
+	if lst.Len() != 2 && lst.Len() != 3 {
+		return time.Time{}, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 2)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := evalLocation(lst, 2, ns)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// timeFormatBody (time-format 時刻 [書式 [タイムゾーン]])
+// UNIX時間の時刻を書式化した文字列を返す。書式を省略した場合はRFC 3339形式にする。
+func timeFormatBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 || lst.Len() > 4 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	t, err := evalAsTime(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	layout, pos := time.RFC3339, lst.Position()
+	if lst.Len() >= 3 {
+		layout, err = EvalAsString(lst.ElementAt(2), ns)
+		if err != nil {
+			return nil, err
+		}
+		pos = lst.ElementAt(2).Position()
+	}
+	loc, err := evalLocation(lst, 3, ns)
+	if err != nil {
+		return nil, err
+	}
+	return formatTime(t.In(loc), layout, pos)
+}
+
+// timeParseBody (time-parse 文字列 [書式 [タイムゾーン]])
+// 文字列を時刻として解釈し、UNIX時間を返す。秒未満の部分がある場合は浮動小数点数になる。書式を省略した場合はISO 8601形式とする。
+// 文字列が時差を含まない場合はタイムゾーンの時刻とする。
+func timeParseBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 || lst.Len() > 4 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 3)
+	}
+	s, err := EvalAsString(lst.ElementAt(1), ns)
+	if err != nil {
+		return nil, err
+	}
+	layout := iso8601Layout
+	if lst.Len() >= 3 {
+		layout, err = EvalAsString(lst.ElementAt(2), ns)
+		if err != nil {
+			return nil, err
+		}
+	}
+	loc, err := evalLocation(lst, 3, ns)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTime(s, layout, loc, lst.ElementAt(1).Position())
+	if err != nil {
+		return nil, err
+	}
+	return timeValue(t), nil
+}
@@ -0,0 +1,288 @@
+package runtime
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/healthy-tiger/scalc/parser"
+)
+
+const (
+	widthSymbol          = "str-width"
+	toZenkakuSymbol      = "str-to-zenkaku"
+	toHankakuSymbol      = "str-to-hankaku"
+	normalizeWidthSymbol = "str-normalize-width"
+	toKatakanaSymbol     = "str-to-katakana"
+	toHiraganaSymbol     = "str-to-hiragana"
+	isDigitSymbol        = "str-is-digit"
+	isLetterSymbol       = "str-is-letter"
+	isSpaceSymbol        = "str-is-space"
+	isHiraganaSymbol     = "str-is-hiragana"
+	isKatakanaSymbol     = "str-is-katakana"
+	isHanSymbol          = "str-is-han"
+	toRunesSymbol        = "str-to-runes"
+	fromRunesSymbol      = "str-from-runes"
+)
+
+const (
+	fullwidthOffset  = 0xFF01 - 0x21 // 全角英数記号と半角英数記号のコードポイントの差
+	hiraganaOffset   = 0x30A1 - 0x3041
+	ideographicSpace = '\u3000'
+	voicedMark       = 'ﾞ' // 半角の濁点
+	semiVoicedMark   = 'ﾟ' // 半角の半濁点
+)
+
+// halfwidthKana U+FF61からU+FF9Fまでの半角カタカナに対応する全角の文字
+const halfwidthKana = "。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン゛゜"
+
+var (
+	// halfwidthKanaRunes halfwidthKanaをruneの配列にしたもの
+	halfwidthKanaRunes = []rune(halfwidthKana)
+	// fullToHalfKana 全角カタカナから半角カタカナ（濁点、半濁点を含む）への変換表
+	fullToHalfKana = make(map[rune]string)
+)
+
+func init() {
+	for i, f := range halfwidthKanaRunes {
+		h := string(rune(0xFF61 + i))
+		fullToHalfKana[f] = h
+		if strings.ContainsRune("カキクケコサシスセソタチツテトハヒフヘホ", f) {
+			fullToHalfKana[f+1] = h + string(voicedMark)
+		}
+		if strings.ContainsRune("ハヒフヘホ", f) {
+			fullToHalfKana[f+2] = h + string(semiVoicedMark)
+		}
+	}
+	fullToHalfKana['ヴ'] = "ｳ" + string(voicedMark)
+}
+
+// runeWidth 端末などでの文字rの表示幅を返す。東アジアの全角文字は2、結合文字と制御文字は0、それ以外は1とする。
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.IsControl(r) || unicode.In(r, unicode.Mn, unicode.Me) || r == '\u200b':
+		return 0
+	case r >= 0xFF61 && r <= 0xFFDC: // 半角カナ、半角ハングル
+		return 1
+	case r >= 0x1100 && r <= 0x115F, // ハングル字母
+		r >= 0x2E80 && r <= 0x303E,   // CJK部首、記号
+		r >= 0x3041 && r <= 0x33FF,   // かな、CJK互換文字
+		r >= 0x3400 && r <= 0x4DBF,   // CJK統合漢字拡張A
+		r >= 0x4E00 && r <= 0x9FFF,   // CJK統合漢字
+		r >= 0xA000 && r <= 0xA4CF,   // イ文字
+		r >= 0xAC00 && r <= 0xD7A3,   // ハングル音節
+		r >= 0xF900 && r <= 0xFAFF,   // CJK互換漢字
+		r >= 0xFE30 && r <= 0xFE4F,   // CJK互換形
+		r >= 0xFF00 && r <= 0xFF60,   // 全角英数記号
+		r >= 0xFFE0 && r <= 0xFFE6,   // 全角記号
+		r >= 0x1F300 && r <= 0x1F64F, // 絵文字
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD: // CJK統合漢字拡張B以降
+		return 2
+	}
+	return 1
+}
+
+// toZenkaku 半角の英数記号、空白、カタカナを全角に変換する。半角カタカナの濁点と半濁点は直前の文字と合成する。
+func toZenkaku(s string) string {
+	var b strings.Builder
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == ' ':
+			b.WriteRune(ideographicSpace)
+		case r >= 0x21 && r <= 0x7E:
+			b.WriteRune(r + fullwidthOffset)
+		case r >= 0xFF61 && r <= 0xFF9F:
+			f := halfwidthKanaRunes[r-0xFF61]
+			if i+1 < len(rs) {
+				if c, ok := composeKana(f, rs[i+1]); ok {
+					f = c
+					i++
+				}
+			}
+			b.WriteRune(f)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// composeKana 全角カタカナfと半角の濁点または半濁点markを合成した文字を返す。合成できない場合はfalseを返す。
+func composeKana(f rune, mark rune) (rune, bool) {
+	switch mark {
+	case voicedMark:
+		if f == 'ウ' {
+			return 'ヴ', true
+		}
+		if strings.ContainsRune("カキクケコサシスセソタチツテトハヒフヘホ", f) {
+			return f + 1, true
+		}
+	case semiVoicedMark:
+		if strings.ContainsRune("ハヒフヘホ", f) {
+			return f + 2, true
+		}
+	}
+	return f, false
+}
+
+// toHankaku 全角の英数記号、空白、カタカナを半角に変換する。濁音と半濁音は濁点、半濁点を分けた2文字にする。
+func toHankaku(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == ideographicSpace:
+			b.WriteRune(' ')
+		case r >= 0xFF01 && r <= 0xFF5E:
+			b.WriteRune(r - fullwidthOffset)
+		default:
+			if h, ok := fullToHalfKana[r]; ok {
+				b.WriteString(h)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// normalizeWidth 比較のために文字幅を揃える。全角の英数記号と空白は半角に、半角カタカナは全角に変換する。
+func normalizeWidth(s string) string {
+	var b strings.Builder
+	for _, r := range toZenkaku(s) {
+		if r == ideographicSpace || (r >= 0xFF01 && r <= 0xFF5E) {
+			b.WriteString(toHankaku(string(r)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// toKatakana ひらがなをカタカナに変換する。
+func toKatakana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 0x3041 && r <= 0x3096) || r == 'ゝ' || r == 'ゞ' {
+			return r + hiraganaOffset
+		}
+		return r
+	}, s)
+}
+
+// toHiragana カタカナをひらがなに変換する。ひらがなにない文字（ヷなど）はそのままにする。
+func toHiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 0x30A1 && r <= 0x30F6) || r == 'ヽ' || r == 'ヾ' {
+			return r - hiraganaOffset
+		}
+		return r
+	}, s)
+}
+
+// stringBody (関数名 文字列)の形式のlstを評価し、文字列をfで変換した結果を返す。
+func stringBody(lst *parser.List, ns *Namespace, f func(string) interface{}) (interface{}, error) {
+	if lst.Len() != 2 {
+		return nil, NewEvalError(lst.Position(), ErrorTheNumberOfArgumentsDoesNotMatch, lst.Len()-1, 1)
+	}
+	a, aerr := EvalAsString(lst.ElementAt(1), ns)
+	if aerr != nil {
+		return nil, aerr
+	}
+	return f(a), nil
+}
+
+// widthBody 文字列の表示幅を返す。
+func widthBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return stringBody(lst, ns, func(s string) interface{} {
+		w := 0
+		for _, r := range s {
+			w += runeWidth(r)
+		}
+		return int64(w)
+	})
+}
+
+// convertBody 文字列を変換する拡張関数の本体。変換する関数をparamに登録する。
+func convertBody(param interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	conv := param.(func(string) string)
+	return stringBody(lst, ns, func(s string) interface{} {
+		return conv(s)
+	})
+}
+
+// classBody 文字列が空でなく、すべての文字が文字の種類を判定する関数paramを満たす場合に真を返す。
+func classBody(param interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	class := param.(func(rune) bool)
+	return stringBody(lst, ns, func(s string) interface{} {
+		if s == "" {
+			return BoolValue(false, ns)
+		}
+		for _, r := range s {
+			if !class(r) {
+				return BoolValue(false, ns)
+			}
+		}
+		return BoolValue(true, ns)
+	})
+}
+
+// toRunesBody 文字列の各文字のコードポイントのリストを返す。
+func toRunesBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	return stringBody(lst, ns, func(s string) interface{} {
+		result := make([]interface{}, 0, len(s))
+		for _, r := range s {
+			result = append(result, int64(r))
+		}
+		return result
+	})
+}
+
+// fromRunesBody (str-from-runes コードポイント ...)
+// コードポイント、またはコードポイントのリストから文字列を作る。
+func fromRunesBody(_ interface{}, lst *parser.List, ns *Namespace) (interface{}, error) {
+	if lst.Len() < 2 {
+		return nil, NewEvalError(lst.Position(), ErrorInsufficientNumberOfArguments, lst.Len()-1, 1)
+	}
+	var b strings.Builder
+	for i := 1; i < lst.Len(); i++ {
+		v, err := EvalElement(lst.ElementAt(i), ns)
+		if err != nil {
+			return nil, err
+		}
+		rs, ok := v.([]interface{})
+		if !ok {
+			rs = []interface{}{v}
+		}
+		for _, e := range rs {
+			r, ok := e.(int64)
+			if !ok {
+				return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorOperantsMustBeOfIntegerType, e)
+			}
+			// サロゲートは単独では文字として書き出せないので範囲外とする。
+			if r < 0 || r > unicode.MaxRune || (r >= 0xD800 && r <= 0xDFFF) {
+				return nil, NewEvalError(lst.ElementAt(i).Position(), ErrorValueOutOfRange, r, 0, unicode.MaxRune)
+			}
+			b.WriteRune(rune(r))
+		}
+	}
+	return b.String(), nil
+}
+
+// RegisterUnicode Unicodeの文字の幅や種類を扱う拡張関数を登録する。
+func RegisterUnicode(ns *Namespace) {
+	ns.RegisterExtension(widthSymbol, nil, widthBody)
+	ns.RegisterExtension(toZenkakuSymbol, toZenkaku, convertBody)
+	ns.RegisterExtension(toHankakuSymbol, toHankaku, convertBody)
+	ns.RegisterExtension(normalizeWidthSymbol, normalizeWidth, convertBody)
+	ns.RegisterExtension(toKatakanaSymbol, toKatakana, convertBody)
+	ns.RegisterExtension(toHiraganaSymbol, toHiragana, convertBody)
+	ns.RegisterExtension(isDigitSymbol, unicode.IsDigit, classBody)
+	ns.RegisterExtension(isLetterSymbol, unicode.IsLetter, classBody)
+	ns.RegisterExtension(isSpaceSymbol, unicode.IsSpace, classBody)
+	ns.RegisterExtension(isHiraganaSymbol, func(r rune) bool { return unicode.Is(unicode.Hiragana, r) }, classBody)
+	ns.RegisterExtension(isKatakanaSymbol, func(r rune) bool { return unicode.Is(unicode.Katakana, r) || r == 'ー' }, classBody)
+	ns.RegisterExtension(isHanSymbol, func(r rune) bool { return unicode.Is(unicode.Han, r) }, classBody)
+	ns.RegisterExtension(toRunesSymbol, nil, toRunesBody)
+	ns.RegisterExtension(fromRunesSymbol, nil, fromRunesBody)
+}